@@ -125,13 +125,7 @@ func podCommand(tc *TestCollector) *Command {
 	} else {
 		b.WriteString(" --all-containers")
 	}
-	if tc.Tail == 0 {
-		if len(tc.Selector) > 0 {
-			tc.Tail = 10
-		} else {
-			tc.Tail = -1
-		}
-	}
+	tc.Tail = podLogsTail(tc.Tail, tc.Selector)
 	fmt.Fprintf(&b, " --tail=%d", tc.Tail)
 	return &Command{
 		Command:       b.String(),
@@ -150,18 +144,7 @@ func (tc *TestCollector) String() string {
 	b.WriteString("[")
 	details := []string{}
 	details = append(details, fmt.Sprintf("type==%s", tc.Type))
-	if len(tc.Pod) > 0 {
-		details = append(details, fmt.Sprintf("pod==%s", tc.Pod))
-	}
-	if len(tc.Selector) > 0 {
-		details = append(details, fmt.Sprintf("label: %s", tc.Selector))
-	}
-	if len(tc.Namespace) > 0 {
-		details = append(details, fmt.Sprintf("namespace: %s", tc.Namespace))
-	}
-	if len(tc.Container) > 0 {
-		details = append(details, fmt.Sprintf("container: %s", tc.Container))
-	}
+	details = append(details, podLogsDetails(tc.Pod, tc.Selector, tc.Namespace, tc.Container)...)
 	if len(tc.Cmd) > 0 {
 		details = append(details, fmt.Sprintf("command: %s", tc.Cmd))
 	}
@@ -169,3 +152,33 @@ func (tc *TestCollector) String() string {
 	b.WriteString("]")
 	return b.String()
 }
+
+// podLogsTail returns tail, or if it is zero the number of last lines of the logs to read by default, which matches
+// `kubectl logs`: 10 for pods selected by a label selector, and -1 (all) for a pod selected by name.
+func podLogsTail(tail int, selector string) int {
+	if tail != 0 {
+		return tail
+	}
+	if len(selector) > 0 {
+		return 10
+	}
+	return -1
+}
+
+// podLogsDetails returns the details describing the pods and container whose logs are read.
+func podLogsDetails(pod, selector, namespace, container string) []string {
+	details := []string{}
+	if len(pod) > 0 {
+		details = append(details, fmt.Sprintf("pod==%s", pod))
+	}
+	if len(selector) > 0 {
+		details = append(details, fmt.Sprintf("label: %s", selector))
+	}
+	if len(namespace) > 0 {
+		details = append(details, fmt.Sprintf("namespace: %s", namespace))
+	}
+	if len(container) > 0 {
+		details = append(details, fmt.Sprintf("container: %s", container))
+	}
+	return details
+}
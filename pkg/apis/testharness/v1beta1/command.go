@@ -15,7 +15,25 @@ limitations under the License.
 
 package v1beta1
 
-import "strings"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// variableName matches the names of environment variables which can be referenced as $NAME.
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedVariables are set by kuttl for every command and can not be overridden by outputs.
+var reservedVariables = map[string]bool{"NAMESPACE": true, "KUBECONFIG": true, "PATH": true}
+
+// reservedPrefix is the prefix of the variables describing the test which runs a command.
+const reservedPrefix = "KUTTL_"
 
 // String returns a human-readable representation of a Command.
 // In particular, when the .Script field is set, we try to omit comments
@@ -55,3 +73,93 @@ func summarize(script string) string {
 	}
 	return joined
 }
+
+// validateVariableName checks that name is a valid environment variable name which is not set by kuttl.
+func validateVariableName(name string) error {
+	if !variableName.MatchString(name) {
+		return fmt.Errorf("%q is not a valid variable name", name)
+	}
+	if reservedVariables[name] || strings.HasPrefix(name, reservedPrefix) {
+		return fmt.Errorf("variable name %q is reserved", name)
+	}
+	return nil
+}
+
+// Validate checks that the output has a valid, not reserved variable name and a valid JSONPath. An output read from an
+// object instead of the standard output requires a complete reference to it and a JSONPath.
+func (o *CommandOutput) Validate() error {
+	if err := validateVariableName(o.Name); err != nil {
+		return fmt.Errorf("invalid output name: %w", err)
+	}
+	if o.JSONPath != "" {
+		if _, err := o.parseJSONPath(); err != nil {
+			return fmt.Errorf("output has invalid jsonPath %q: %w", o.JSONPath, err)
+		}
+	}
+	if o.Object != nil {
+		if o.Object.APIVersion == "" || o.Object.Kind == "" || o.Object.Name == "" {
+			return errors.New("output object requires apiVersion, kind and name")
+		}
+		if o.JSONPath == "" {
+			return errors.New("output object requires jsonPath")
+		}
+	}
+	return nil
+}
+
+// Value returns the value of the variable for the standard output of a command.
+func (o *CommandOutput) Value(stdout []byte) (string, error) {
+	if o.JSONPath == "" {
+		return strings.TrimRight(string(stdout), "\r\n"), nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(stdout, &data); err != nil {
+		return "", fmt.Errorf("output is not JSON: %w", err)
+	}
+	return o.evaluate(data)
+}
+
+// ObjectValue returns the value of the variable for the fetched Object, given as the content of an unstructured
+// object.
+func (o *CommandOutput) ObjectValue(obj map[string]interface{}) (string, error) {
+	return o.evaluate(obj)
+}
+
+// evaluate evaluates the JSONPath against data.
+func (o *CommandOutput) evaluate(data interface{}) (string, error) {
+	parser, err := o.parseJSONPath()
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := parser.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("jsonpath %s: %w", o.JSONPath, err)
+	}
+	return buf.String(), nil
+}
+
+// parseJSONPath parses the JSONPath, adding the braces if they have been omitted. Unlike the JSONPath assertions,
+// missing keys are an error.
+func (o *CommandOutput) parseJSONPath() (*jsonpath.JSONPath, error) {
+	template := o.JSONPath
+	if !strings.Contains(template, "{") {
+		template = fmt.Sprintf("{%s}", template)
+	}
+	parser := jsonpath.New("output")
+	if err := parser.Parse(template); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
+
+// ValidateOutput checks the output of the command, if it is set. Background commands can not capture their output.
+func (c *Command) ValidateOutput() error {
+	if c.Output == nil {
+		return nil
+	}
+	if c.Background {
+		return errors.New("output can not be captured for background commands")
+	}
+	return c.Output.Validate()
+}
@@ -65,3 +65,81 @@ AAAAAAAAAAAAAAAAA AAAAAAAAAAAAAAAAA AAAAAAAAAAAAAAAAA AAAAAAAAAAAAAAAAA
 		})
 	}
 }
+
+func TestCommand_ValidateOutput(t *testing.T) {
+	tests := map[string]struct {
+		command Command
+		wantErr string
+	}{
+		"no output": {},
+		"valid": {
+			command: Command{Output: &CommandOutput{Name: "POD_IP", JSONPath: ".status.podIP"}},
+		},
+		"invalid name": {
+			command: Command{Output: &CommandOutput{Name: "pod-ip"}},
+			wantErr: "not a valid variable name",
+		},
+		"reserved name": {
+			command: Command{Output: &CommandOutput{Name: "NAMESPACE"}},
+			wantErr: "reserved",
+		},
+		"invalid jsonpath": {
+			command: Command{Output: &CommandOutput{Name: "POD_IP", JSONPath: "{.status"}},
+			wantErr: "invalid jsonPath",
+		},
+		"background": {
+			command: Command{Background: true, Output: &CommandOutput{Name: "POD_IP"}},
+			wantErr: "background",
+		},
+		"object": {
+			command: Command{Output: &CommandOutput{Name: "POD_IP", JSONPath: ".status.podIP", Object: &OutputObjectReference{APIVersion: "v1", Kind: "Pod", Name: "zookeeper-0"}}},
+		},
+		"incomplete object": {
+			command: Command{Output: &CommandOutput{Name: "POD_IP", JSONPath: ".status.podIP", Object: &OutputObjectReference{APIVersion: "v1", Kind: "Pod"}}},
+			wantErr: "output object requires apiVersion, kind and name",
+		},
+		"object without jsonpath": {
+			command: Command{Output: &CommandOutput{Name: "POD", Object: &OutputObjectReference{APIVersion: "v1", Kind: "Pod", Name: "zookeeper-0"}}},
+			wantErr: "output object requires jsonPath",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			err := tt.command.ValidateOutput()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommandOutput_Value(t *testing.T) {
+	output := &CommandOutput{Name: "NAME"}
+	value, err := output.Value([]byte("hello\n\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", value)
+
+	output.JSONPath = ".metadata.uid"
+	value, err = output.Value([]byte(`{"metadata": {"name": "hello", "uid": "1234"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", value)
+
+	_, err = output.Value([]byte(`{"metadata": {"name": "hello"}}`))
+	assert.ErrorContains(t, err, "uid is not found")
+
+	_, err = output.Value([]byte("hello"))
+	assert.ErrorContains(t, err, "output is not JSON")
+}
+
+func TestCommandOutput_ObjectValue(t *testing.T) {
+	output := &CommandOutput{Name: "UID", JSONPath: ".metadata.uid", Object: &OutputObjectReference{APIVersion: "v1", Kind: "Pod", Name: "hello"}}
+	value, err := output.ObjectValue(map[string]interface{}{"metadata": map[string]interface{}{"name": "hello", "uid": "1234"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", value)
+
+	_, err = output.ObjectValue(map[string]interface{}{"metadata": map[string]interface{}{"name": "hello"}})
+	assert.ErrorContains(t, err, "uid is not found")
+}
@@ -0,0 +1,48 @@
+package v1beta1
+
+import "fmt"
+
+// Validate checks that the environment variable has a valid, not reserved name and either a value or exactly one
+// source.
+func (e *EnvVar) Validate() error {
+	if err := validateVariableName(e.Name); err != nil {
+		return err
+	}
+	if e.ValueFrom == nil {
+		return nil
+	}
+	if e.Value != "" {
+		return fmt.Errorf("environment variable %s can not have both value and valueFrom", e.Name)
+	}
+
+	sources := 0
+	if ref := e.ValueFrom.SecretKeyRef; ref != nil {
+		if ref.Name == "" || ref.Key == "" {
+			return fmt.Errorf("environment variable %s: secretKeyRef requires name and key", e.Name)
+		}
+		sources++
+	}
+	if ref := e.ValueFrom.ConfigMapKeyRef; ref != nil {
+		if ref.Name == "" || ref.Key == "" {
+			return fmt.Errorf("environment variable %s: configMapKeyRef requires name and key", e.Name)
+		}
+		sources++
+	}
+	if e.ValueFrom.File != "" {
+		sources++
+	}
+	if sources != 1 {
+		return fmt.Errorf("environment variable %s: valueFrom requires exactly one of secretKeyRef, configMapKeyRef or file", e.Name)
+	}
+	return nil
+}
+
+// ValidateEnv checks all environment variables of env.
+func ValidateEnv(env []EnvVar) error {
+	for i := range env {
+		if err := env[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestEnvVarValidate(t *testing.T) {
+	secretRef := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"}, Key: "password"}
+
+	tests := []struct {
+		name   string
+		envVar EnvVar
+		err    string
+	}{
+		{name: "value", envVar: EnvVar{Name: "STORAGE_CLASS", Value: "standard"}},
+		{name: "empty value", envVar: EnvVar{Name: "STORAGE_CLASS"}},
+		{name: "secret", envVar: EnvVar{Name: "PASSWORD", ValueFrom: &EnvVarSource{SecretKeyRef: secretRef}}},
+		{name: "file", envVar: EnvVar{Name: "TOKEN", ValueFrom: &EnvVarSource{File: "token.txt"}}},
+		{name: "invalid name", envVar: EnvVar{Name: "storage-class"}, err: `"storage-class" is not a valid variable name`},
+		{name: "reserved name", envVar: EnvVar{Name: "KUBECONFIG"}, err: `variable name "KUBECONFIG" is reserved`},
+		{name: "reserved prefix", envVar: EnvVar{Name: "KUTTL_TEST"}, err: `variable name "KUTTL_TEST" is reserved`},
+		{
+			name:   "value and valueFrom",
+			envVar: EnvVar{Name: "PASSWORD", Value: "secret", ValueFrom: &EnvVarSource{SecretKeyRef: secretRef}},
+			err:    "environment variable PASSWORD can not have both value and valueFrom",
+		},
+		{
+			name:   "no source",
+			envVar: EnvVar{Name: "PASSWORD", ValueFrom: &EnvVarSource{}},
+			err:    "environment variable PASSWORD: valueFrom requires exactly one of secretKeyRef, configMapKeyRef or file",
+		},
+		{
+			name:   "two sources",
+			envVar: EnvVar{Name: "PASSWORD", ValueFrom: &EnvVarSource{SecretKeyRef: secretRef, File: "password.txt"}},
+			err:    "environment variable PASSWORD: valueFrom requires exactly one of secretKeyRef, configMapKeyRef or file",
+		},
+		{
+			name:   "configmap without key",
+			envVar: EnvVar{Name: "CONFIG", ValueFrom: &EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}}},
+			err:    "environment variable CONFIG: configMapKeyRef requires name and key",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.envVar.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validate checks that the event assertion has a known type, a valid note regex and a non-negative minimum count.
+func (e *TestEventAssert) Validate() error {
+	_, err := e.Compile()
+	return err
+}
+
+// Compile validates the event assertion like Validate and returns its compiled note regex, which is nil if the
+// event assertion has no note.
+func (e *TestEventAssert) Compile() (*regexp.Regexp, error) {
+	switch e.Type {
+	case "", "Normal", "Warning":
+	default:
+		return nil, fmt.Errorf("event assertion has unknown type %q, expected Normal or Warning", e.Type)
+	}
+	if e.MinCount < 0 {
+		return nil, errors.New("event assertion minCount must not be negative")
+	}
+	if e.Note == "" {
+		return nil, nil
+	}
+	note, err := regexp.Compile(e.Note)
+	if err != nil {
+		return nil, fmt.Errorf("event assertion has invalid note regex %q: %w", e.Note, err)
+	}
+	return note, nil
+}
+
+// GetMinCount returns the number of times matching events must have occurred.
+func (e *TestEventAssert) GetMinCount() int {
+	if e.MinCount == 0 {
+		return 1
+	}
+	return e.MinCount
+}
+
+// String returns a human-readable representation of the events the event assertion matches.
+func (e *TestEventAssert) String() string {
+	details := []string{}
+	if len(e.Type) > 0 {
+		details = append(details, fmt.Sprintf("type==%s", e.Type))
+	}
+	if len(e.Reason) > 0 {
+		details = append(details, fmt.Sprintf("reason==%s", e.Reason))
+	}
+	if e.Regarding != (EventObjectReference{}) {
+		details = append(details, fmt.Sprintf("regarding==%s/%s %s", e.Regarding.APIVersion, e.Regarding.Kind, e.Regarding.Name))
+	}
+	if len(e.Namespace) > 0 {
+		details = append(details, fmt.Sprintf("namespace: %s", e.Namespace))
+	}
+	if len(e.Note) > 0 {
+		details = append(details, fmt.Sprintf("note: %s", e.Note))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(details, ","))
+}
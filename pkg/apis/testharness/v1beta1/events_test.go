@@ -0,0 +1,43 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestEventAssertValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		eventAssert TestEventAssert
+		err         string
+	}{
+		{name: "reason", eventAssert: TestEventAssert{Reason: "Started"}},
+		{name: "warning with note", eventAssert: TestEventAssert{Type: "Warning", Note: "^Back-off"}},
+		{name: "unknown type", eventAssert: TestEventAssert{Type: "Error"}, err: `event assertion has unknown type "Error", expected Normal or Warning`},
+		{name: "invalid note regex", eventAssert: TestEventAssert{Note: "("}, err: "event assertion has invalid note regex \"(\": error parsing regexp: missing closing ): `(`"},
+		{name: "negative minCount", eventAssert: TestEventAssert{Reason: "Started", MinCount: -1}, err: "event assertion minCount must not be negative"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.eventAssert.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTestEventAssertCompile(t *testing.T) {
+	note, err := (&TestEventAssert{Reason: "Started"}).Compile()
+	assert.NoError(t, err)
+	assert.Nil(t, note)
+
+	note, err = (&TestEventAssert{Note: "^Back-off"}).Compile()
+	assert.NoError(t, err)
+	assert.True(t, note.MatchString("Back-off restarting failed container"))
+	assert.False(t, note.MatchString("Started container main"))
+}
@@ -0,0 +1,41 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// celIdentifierRegex matches valid CEL identifiers which are not reserved words.
+var celIdentifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// celReservedWords are the identifiers CEL reserves for its own use.
+var celReservedWords = map[string]bool{
+	"true": true, "false": true, "null": true, "in": true,
+	"as": true, "break": true, "const": true, "continue": true, "else": true,
+	"for": true, "function": true, "if": true, "import": true, "let": true,
+	"loop": true, "package": true, "namespace": true, "return": true,
+	"var": true, "void": true, "while": true,
+}
+
+// Validate checks that the resource reference is complete and that its Ref can be used as a CEL identifier.
+func (r *TestResourceRef) Validate() error {
+	if r.APIVersion == "" || r.Kind == "" {
+		return errors.New("apiVersion and kind must be set")
+	}
+	if r.Name == "" {
+		return errors.New("name must be set")
+	}
+	if r.Ref == "" {
+		return errors.New("ref must be set")
+	}
+	if !celIdentifierRegex.MatchString(r.Ref) || celReservedWords[r.Ref] {
+		return fmt.Errorf("ref %q is not a valid CEL identifier", r.Ref)
+	}
+	return nil
+}
+
+// String returns a human-readable representation of the resource reference.
+func (r *TestResourceRef) String() string {
+	return fmt.Sprintf("%s=%s/%s:%s/%s", r.Ref, r.APIVersion, r.Kind, r.Namespace, r.Name)
+}
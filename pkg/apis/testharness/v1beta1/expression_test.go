@@ -0,0 +1,56 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestResourceRef_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     TestResourceRef
+		wantErr string
+	}{
+		{
+			name: "valid",
+			ref:  TestResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "coredns", Ref: "coredns_deployment"},
+		},
+		{
+			name:    "missing kind",
+			ref:     TestResourceRef{APIVersion: "apps/v1", Name: "coredns", Ref: "coredns"},
+			wantErr: "apiVersion and kind must be set",
+		},
+		{
+			name:    "missing name",
+			ref:     TestResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Ref: "coredns"},
+			wantErr: "name must be set",
+		},
+		{
+			name:    "missing ref",
+			ref:     TestResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "coredns"},
+			wantErr: "ref must be set",
+		},
+		{
+			name:    "invalid identifier",
+			ref:     TestResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "coredns", Ref: "core-dns"},
+			wantErr: `ref "core-dns" is not a valid CEL identifier`,
+		},
+		{
+			name:    "reserved word",
+			ref:     TestResourceRef{APIVersion: "v1", Kind: "Namespace", Name: "default", Ref: "namespace"},
+			wantErr: `ref "namespace" is not a valid CEL identifier`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ref.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Validate checks that the HTTP assertion targets either a service or a pod and that its expectations are valid.
+func (h *TestHTTPAssert) Validate() error {
+	_, _, err := h.Compile()
+	return err
+}
+
+// Compile validates the HTTP assertion like Validate and returns its parsed path and its compiled body regex, which
+// is nil if the HTTP assertion has no body regex.
+func (h *TestHTTPAssert) Compile() (*url.URL, *regexp.Regexp, error) {
+	if (h.Service == "") == (h.Pod == "") {
+		return nil, nil, errors.New("http assertion requires either a service or a pod")
+	}
+	if h.Port.String() == "" || h.Port.String() == "0" {
+		return nil, nil, errors.New("http assertion requires a port")
+	}
+	switch h.Scheme {
+	case "", "http", "https":
+	default:
+		return nil, nil, fmt.Errorf("http assertion has unknown scheme %q, expected http or https", h.Scheme)
+	}
+	if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+		return nil, nil, fmt.Errorf("http assertion path %q must start with /", h.Path)
+	}
+	path, err := url.Parse(h.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("http assertion has invalid path %q: %w", h.Path, err)
+	}
+	if h.StatusCode != 0 && (h.StatusCode < 100 || h.StatusCode > 599) {
+		return nil, nil, fmt.Errorf("http assertion has invalid status code %d", h.StatusCode)
+	}
+	if h.BodyRegex == "" {
+		return path, nil, nil
+	}
+	bodyRegex, err := regexp.Compile(h.BodyRegex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("http assertion has invalid body regex %q: %w", h.BodyRegex, err)
+	}
+	return path, bodyRegex, nil
+}
+
+// GetMethod returns the HTTP method of the request.
+func (h *TestHTTPAssert) GetMethod() string {
+	if h.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(h.Method)
+}
+
+// GetStatusCode returns the expected status code of the response.
+func (h *TestHTTPAssert) GetStatusCode() int {
+	if h.StatusCode == 0 {
+		return http.StatusOK
+	}
+	return h.StatusCode
+}
+
+// String returns a human-readable representation of the endpoint the HTTP assertion requests.
+func (h *TestHTTPAssert) String() string {
+	details := []string{}
+	if len(h.Service) > 0 {
+		details = append(details, fmt.Sprintf("service==%s:%s", h.Service, h.Port.String()))
+	}
+	if len(h.Pod) > 0 {
+		details = append(details, fmt.Sprintf("pod==%s:%s", h.Pod, h.Port.String()))
+	}
+	if len(h.Namespace) > 0 {
+		details = append(details, fmt.Sprintf("namespace: %s", h.Namespace))
+	}
+	path := h.Path
+	if path == "" {
+		path = "/"
+	}
+	details = append(details, fmt.Sprintf("%s %s", h.GetMethod(), path))
+	return fmt.Sprintf("[%s]", strings.Join(details, ","))
+}
@@ -0,0 +1,38 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestTestHTTPAssertValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		httpAssert TestHTTPAssert
+		err        string
+	}{
+		{name: "service", httpAssert: TestHTTPAssert{Service: "web", Port: intstr.FromString("http"), Path: "/healthz"}},
+		{name: "pod", httpAssert: TestHTTPAssert{Pod: "web-0", Port: intstr.FromInt32(8443), Scheme: "https", StatusCode: 204}},
+		{name: "neither service nor pod", httpAssert: TestHTTPAssert{Port: intstr.FromInt32(80)}, err: "http assertion requires either a service or a pod"},
+		{name: "service and pod", httpAssert: TestHTTPAssert{Service: "web", Pod: "web-0", Port: intstr.FromInt32(80)}, err: "http assertion requires either a service or a pod"},
+		{name: "no port", httpAssert: TestHTTPAssert{Service: "web"}, err: "http assertion requires a port"},
+		{name: "unknown scheme", httpAssert: TestHTTPAssert{Service: "web", Port: intstr.FromInt32(80), Scheme: "ftp"}, err: `http assertion has unknown scheme "ftp", expected http or https`},
+		{name: "relative path", httpAssert: TestHTTPAssert{Service: "web", Port: intstr.FromInt32(80), Path: "healthz"}, err: `http assertion path "healthz" must start with /`},
+		{name: "invalid path", httpAssert: TestHTTPAssert{Service: "web", Port: intstr.FromInt32(80), Path: "/%zz"}, err: `http assertion has invalid path "/%zz": parse "/%zz": invalid URL escape "%zz"`},
+		{name: "invalid status code", httpAssert: TestHTTPAssert{Service: "web", Port: intstr.FromInt32(80), StatusCode: 42}, err: "http assertion has invalid status code 42"},
+		{name: "invalid body regex", httpAssert: TestHTTPAssert{Service: "web", Port: intstr.FromInt32(80), BodyRegex: "("}, err: "http assertion has invalid body regex \"(\": error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.httpAssert.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Validate checks that the JSONPath assertion references a kind and has a valid path.
+func (j *TestJSONPathAssert) Validate() error {
+	_, err := j.Compile()
+	return err
+}
+
+// Compile validates the JSONPath assertion like Validate and returns its parsed path.
+func (j *TestJSONPathAssert) Compile() (*jsonpath.JSONPath, error) {
+	if j.APIVersion == "" || j.Kind == "" {
+		return nil, errors.New("jsonpath assertion requires apiVersion and kind")
+	}
+	if j.Name != "" && len(j.Labels) > 0 {
+		return nil, errors.New("jsonpath assertion requires either a name or labels")
+	}
+	if j.Path == "" {
+		return nil, errors.New("jsonpath assertion requires a path")
+	}
+	parser, err := j.JSONPath()
+	if err != nil {
+		return nil, fmt.Errorf("jsonpath assertion has invalid path %q: %w", j.Path, err)
+	}
+	return parser, nil
+}
+
+// Template returns the path as a JSONPath template, adding the braces if they have been omitted.
+func (j *TestJSONPathAssert) Template() string {
+	if strings.Contains(j.Path, "{") {
+		return j.Path
+	}
+	return fmt.Sprintf("{%s}", j.Path)
+}
+
+// JSONPath returns the parsed path. Like `kubectl get -o jsonpath`, missing keys evaluate to an empty result.
+func (j *TestJSONPathAssert) JSONPath() (*jsonpath.JSONPath, error) {
+	parser := jsonpath.New("assert").AllowMissingKeys(true)
+	if err := parser.Parse(j.Template()); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
@@ -0,0 +1,39 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestJSONPathAssertValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		jsonPathAssert TestJSONPathAssert
+		err            string
+	}{
+		{name: "template", jsonPathAssert: TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "hello", Path: "{.status.phase}", Value: "Running"}},
+		{name: "labels", jsonPathAssert: TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Labels: map[string]string{"app": "hello"}, Path: ".status.phase"}},
+		{name: "no kind", jsonPathAssert: TestJSONPathAssert{APIVersion: "v1", Name: "hello", Path: "{.status.phase}"}, err: "jsonpath assertion requires apiVersion and kind"},
+		{name: "name and labels", jsonPathAssert: TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "hello", Labels: map[string]string{"app": "hello"}, Path: "{.status.phase}"}, err: "jsonpath assertion requires either a name or labels"},
+		{name: "no path", jsonPathAssert: TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "hello"}, err: "jsonpath assertion requires a path"},
+		{name: "invalid path", jsonPathAssert: TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "hello", Path: "{.status[}"}, err: `jsonpath assertion has invalid path "{.status[}": unterminated array`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.jsonPathAssert.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTestJSONPathAssertTemplate(t *testing.T) {
+	assert.Equal(t, "{.status.phase}", (&TestJSONPathAssert{Path: ".status.phase"}).Template())
+	assert.Equal(t, "{.status.phase}", (&TestJSONPathAssert{Path: "{.status.phase}"}).Template())
+	assert.Equal(t, "{range .items[*]}{.name} {end}", (&TestJSONPathAssert{Path: "{range .items[*]}{.name} {end}"}).Template())
+}
@@ -0,0 +1,26 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks that the hook has commands or steps and that the outputs and environment variables of its
+// commands are valid. Background commands are only valid if allowBackground is set.
+func (h *LifecycleHook) Validate(allowBackground bool) error {
+	if len(h.Commands) == 0 && h.Steps == "" {
+		return errors.New("lifecycle hook requires commands or steps")
+	}
+	for i := range h.Commands {
+		if h.Commands[i].Background && !allowBackground {
+			return fmt.Errorf("command %s can not be run in the background", h.Commands[i].String())
+		}
+		if err := h.Commands[i].ValidateOutput(); err != nil {
+			return fmt.Errorf("command %s: %w", h.Commands[i].String(), err)
+		}
+		if err := ValidateEnv(h.Commands[i].Env); err != nil {
+			return fmt.Errorf("command %s: %w", h.Commands[i].String(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycleHookValidate(t *testing.T) {
+	tests := []struct {
+		name            string
+		hook            LifecycleHook
+		allowBackground bool
+		err             string
+	}{
+		{name: "commands", hook: LifecycleHook{Commands: []Command{{Command: "kubectl version"}}}},
+		{name: "steps", hook: LifecycleHook{Steps: "tests/hooks/before-each"}},
+		{name: "empty", hook: LifecycleHook{}, err: "lifecycle hook requires commands or steps"},
+		{name: "background", hook: LifecycleHook{Commands: []Command{{Command: "./bin/manager", Background: true}}}, allowBackground: true},
+		{name: "background not allowed", hook: LifecycleHook{Commands: []Command{{Command: "./bin/manager", Background: true}}}, err: "command ./bin/manager can not be run in the background"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hook.Validate(tt.allowBackground)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
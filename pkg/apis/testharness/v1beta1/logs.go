@@ -0,0 +1,51 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validate checks that the log assertion selects pods and has at least one valid condition.
+func (l *TestLogAssert) Validate() error {
+	if (l.Pod == "") == (l.Selector == "") {
+		return errors.New("log assertion requires either a pod or a selector")
+	}
+	if l.Contains == "" && l.Regex == "" && l.NotContains == "" && l.NotRegex == "" {
+		return errors.New("log assertion requires at least one of contains, regex, notContains or notRegex")
+	}
+	_, _, err := l.Compile()
+	return err
+}
+
+// Compile returns the compiled regex and notRegex of the log assertion, which are nil if they are not set.
+func (l *TestLogAssert) Compile() (*regexp.Regexp, *regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 2)
+	for i, expr := range []string{l.Regex, l.NotRegex} {
+		if expr == "" {
+			continue
+		}
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("log assertion has invalid regex %q: %w", expr, err)
+		}
+		compiled[i] = regex
+	}
+	return compiled[0], compiled[1], nil
+}
+
+// TailLines returns the number of last lines to check, or nil for all lines. It defaults like the tail of a pod
+// collector.
+func (l *TestLogAssert) TailLines() *int64 {
+	tail := int64(podLogsTail(l.Tail, l.Selector))
+	if tail < 0 {
+		return nil
+	}
+	return &tail
+}
+
+// String returns a human-readable representation of the pods and container the log assertion selects.
+func (l *TestLogAssert) String() string {
+	return fmt.Sprintf("[%s]", strings.Join(podLogsDetails(l.Pod, l.Selector, l.Namespace, l.Container), ","))
+}
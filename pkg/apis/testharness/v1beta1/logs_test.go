@@ -0,0 +1,72 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestLogAssertValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		logAssert TestLogAssert
+		err       string
+	}{
+		{name: "pod", logAssert: TestLogAssert{Pod: "hello", Contains: "started"}},
+		{name: "selector", logAssert: TestLogAssert{Selector: "app=hello", NotRegex: "(?i)panic"}},
+		{name: "neither pod nor selector", logAssert: TestLogAssert{Contains: "started"}, err: "log assertion requires either a pod or a selector"},
+		{name: "pod and selector", logAssert: TestLogAssert{Pod: "hello", Selector: "app=hello", Contains: "started"}, err: "log assertion requires either a pod or a selector"},
+		{name: "no condition", logAssert: TestLogAssert{Pod: "hello"}, err: "log assertion requires at least one of contains, regex, notContains or notRegex"},
+		{name: "invalid regex", logAssert: TestLogAssert{Pod: "hello", Regex: "("}, err: "log assertion has invalid regex \"(\": error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.logAssert.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTestLogAssertTailLines(t *testing.T) {
+	assert.Nil(t, (&TestLogAssert{Pod: "hello"}).TailLines())
+	assert.Equal(t, int64(10), *(&TestLogAssert{Selector: "app=hello"}).TailLines())
+	assert.Nil(t, (&TestLogAssert{Selector: "app=hello", Tail: -1}).TailLines())
+	assert.Equal(t, int64(5), *(&TestLogAssert{Pod: "hello", Tail: 5}).TailLines())
+}
+
+func TestTestLogAssertTailLinesMatchesCollector(t *testing.T) {
+	for _, tt := range []struct {
+		pod, selector string
+		tail          int
+		expected      string
+	}{
+		{pod: "hello", expected: "--tail=-1"},
+		{selector: "app=hello", expected: "--tail=10"},
+		{selector: "app=hello", tail: -1, expected: "--tail=-1"},
+		{pod: "hello", tail: 5, expected: "--tail=5"},
+	} {
+		collector := &TestCollector{Type: pod, Pod: tt.pod, Selector: tt.selector, Tail: tt.tail}
+		assert.True(t, strings.HasSuffix(collector.Command().Command, " "+tt.expected), collector.Command().Command)
+
+		tailLines := (&TestLogAssert{Pod: tt.pod, Selector: tt.selector, Tail: tt.tail}).TailLines()
+		if tt.expected == "--tail=-1" {
+			assert.Nil(t, tailLines)
+		} else {
+			assert.Equal(t, tt.expected, fmt.Sprintf("--tail=%d", *tailLines))
+		}
+	}
+}
+
+func TestTestLogAssertString(t *testing.T) {
+	logAssert := &TestLogAssert{Selector: "app=hello", Namespace: "world", Container: "main", Contains: "started"}
+	collector := &TestCollector{Type: pod, Selector: "app=hello", Namespace: "world", Container: "main"}
+	assert.Equal(t, "[label: app=hello,namespace: world,container: main]", logAssert.String())
+	assert.Equal(t, "[type==pod,label: app=hello,namespace: world,container: main]", collector.String())
+}
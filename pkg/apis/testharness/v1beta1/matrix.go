@@ -0,0 +1,48 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks that the matrix has dimensions, each with a distinct, valid variable name and at least one value.
+func (m *TestMatrix) Validate() error {
+	if len(m.Dimensions) == 0 {
+		return errors.New("matrix requires dimensions")
+	}
+	names := map[string]bool{}
+	for _, dimension := range m.Dimensions {
+		if err := validateVariableName(dimension.Name); err != nil {
+			return fmt.Errorf("invalid dimension: %w", err)
+		}
+		if names[dimension.Name] {
+			return fmt.Errorf("dimension %s is declared more than once", dimension.Name)
+		}
+		names[dimension.Name] = true
+		if len(dimension.Values) == 0 {
+			return fmt.Errorf("dimension %s requires values", dimension.Name)
+		}
+	}
+	return nil
+}
+
+// Combinations returns the variables of every combination of the values of the dimensions. The values of the last
+// dimension vary fastest.
+func (m *TestMatrix) Combinations() []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, dimension := range m.Dimensions {
+		next := make([]map[string]string, 0, len(combinations)*len(dimension.Values))
+		for _, combination := range combinations {
+			for _, value := range dimension.Values {
+				variables := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					variables[k] = v
+				}
+				variables[dimension.Name] = value
+				next = append(next, variables)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
@@ -0,0 +1,70 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatrixValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		matrix TestMatrix
+		err    string
+	}{
+		{
+			name:   "valid",
+			matrix: TestMatrix{Dimensions: []MatrixDimension{{Name: "TLS", Values: []string{"true", "false"}}}},
+		},
+		{
+			name: "no dimensions",
+			err:  "matrix requires dimensions",
+		},
+		{
+			name:   "invalid name",
+			matrix: TestMatrix{Dimensions: []MatrixDimension{{Name: "storage-class", Values: []string{"standard"}}}},
+			err:    `invalid dimension: "storage-class" is not a valid variable name`,
+		},
+		{
+			name:   "reserved name",
+			matrix: TestMatrix{Dimensions: []MatrixDimension{{Name: "NAMESPACE", Values: []string{"default"}}}},
+			err:    `invalid dimension: variable name "NAMESPACE" is reserved`,
+		},
+		{
+			name: "duplicate name",
+			matrix: TestMatrix{Dimensions: []MatrixDimension{
+				{Name: "TLS", Values: []string{"true"}},
+				{Name: "TLS", Values: []string{"false"}},
+			}},
+			err: "dimension TLS is declared more than once",
+		},
+		{
+			name:   "no values",
+			matrix: TestMatrix{Dimensions: []MatrixDimension{{Name: "TLS"}}},
+			err:    "dimension TLS requires values",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.matrix.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMatrixCombinations(t *testing.T) {
+	matrix := TestMatrix{Dimensions: []MatrixDimension{
+		{Name: "VERSION", Values: []string{"3.8", "3.9"}},
+		{Name: "TLS", Values: []string{"true", "false"}},
+	}}
+	assert.Equal(t, []map[string]string{
+		{"VERSION": "3.8", "TLS": "true"},
+		{"VERSION": "3.8", "TLS": "false"},
+		{"VERSION": "3.9", "TLS": "true"},
+		{"VERSION": "3.9", "TLS": "false"},
+	}, matrix.Combinations())
+}
@@ -0,0 +1,80 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Validate checks that the patch references a kind and has exactly one well-formed patch document.
+func (p *TestPatch) Validate() error {
+	if p.APIVersion == "" || p.Kind == "" {
+		return errors.New("patch requires apiVersion and kind")
+	}
+
+	set := 0
+	for _, patch := range []bool{p.JSONPatch != nil, p.MergePatch != nil, p.StrategicMergePatch != nil} {
+		if patch {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("patch requires exactly one of jsonPatch, mergePatch or strategicMergePatch")
+	}
+
+	patchType, data := p.Data()
+	if patchType == types.JSONPatchType {
+		operations := []map[string]interface{}{}
+		if err := json.Unmarshal(data, &operations); err != nil {
+			return fmt.Errorf("jsonPatch must be a list of operations: %w", err)
+		}
+		for i, operation := range operations {
+			if _, ok := operation["op"].(string); !ok {
+				return fmt.Errorf("jsonPatch operation %d has no op", i)
+			}
+			if _, ok := operation["path"].(string); !ok {
+				return fmt.Errorf("jsonPatch operation %d has no path", i)
+			}
+		}
+	} else {
+		field := "mergePatch"
+		if patchType == types.StrategicMergePatchType {
+			field = "strategicMergePatch"
+		}
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return fmt.Errorf("%s must be an object: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// Data returns the type and the JSON document of the patch.
+func (p *TestPatch) Data() (types.PatchType, []byte) {
+	switch {
+	case p.JSONPatch != nil:
+		return types.JSONPatchType, p.JSONPatch.Raw
+	case p.StrategicMergePatch != nil:
+		return types.StrategicMergePatchType, p.StrategicMergePatch.Raw
+	case p.MergePatch != nil:
+		return types.MergePatchType, p.MergePatch.Raw
+	}
+	return "", nil
+}
+
+// String returns a human-readable representation of the objects the patch applies to.
+func (p *TestPatch) String() string {
+	ref := fmt.Sprintf("%s/%s", p.APIVersion, p.Kind)
+	if p.Namespace != "" {
+		ref = fmt.Sprintf("%s %s", ref, p.Namespace)
+	}
+	if p.Name != "" {
+		return fmt.Sprintf("%s %s", ref, p.Name)
+	}
+	if len(p.Labels) > 0 {
+		return fmt.Sprintf("%s with labels %v", ref, p.Labels)
+	}
+	return ref
+}
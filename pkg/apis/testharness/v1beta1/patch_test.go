@@ -0,0 +1,41 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestTestPatchValidate(t *testing.T) {
+	statefulSet := ObjectReference{ObjectReference: corev1.ObjectReference{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "zk"}}
+	raw := func(patch string) *apiextv1.JSON { return &apiextv1.JSON{Raw: []byte(patch)} }
+
+	tests := []struct {
+		name  string
+		patch TestPatch
+		err   string
+	}{
+		{name: "json patch", patch: TestPatch{ObjectReference: statefulSet, JSONPatch: raw(`[{"op": "replace", "path": "/spec/replicas", "value": 0}]`)}},
+		{name: "merge patch", patch: TestPatch{ObjectReference: statefulSet, MergePatch: raw(`{"spec": {"replicas": 0}}`)}},
+		{name: "strategic merge patch", patch: TestPatch{ObjectReference: statefulSet, StrategicMergePatch: raw(`{"spec": {"replicas": 0}}`)}},
+		{name: "no kind", patch: TestPatch{MergePatch: raw(`{}`)}, err: "patch requires apiVersion and kind"},
+		{name: "no patch", patch: TestPatch{ObjectReference: statefulSet}, err: "patch requires exactly one of jsonPatch, mergePatch or strategicMergePatch"},
+		{name: "two patches", patch: TestPatch{ObjectReference: statefulSet, MergePatch: raw(`{}`), StrategicMergePatch: raw(`{}`)}, err: "patch requires exactly one of jsonPatch, mergePatch or strategicMergePatch"},
+		{name: "json patch object", patch: TestPatch{ObjectReference: statefulSet, JSONPatch: raw(`{"spec": {}}`)}, err: "jsonPatch must be a list of operations: json: cannot unmarshal object into Go value of type []map[string]interface {}"},
+		{name: "json patch without path", patch: TestPatch{ObjectReference: statefulSet, JSONPatch: raw(`[{"op": "remove"}]`)}, err: "jsonPatch operation 0 has no path"},
+		{name: "merge patch list", patch: TestPatch{ObjectReference: statefulSet, StrategicMergePatch: raw(`[]`)}, err: "strategicMergePatch must be an object: json: cannot unmarshal array into Go value of type map[string]interface {}"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.patch.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -2,13 +2,51 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/rest"
 )
 
 const KubeconfigLoadingEager = "Eager"
 const KubeconfigLoadingLazy = "Lazy"
 
+// ApplyStrategyMerge, ApplyStrategyStrategicMerge, ApplyStrategyServerSide and ApplyStrategyReplace are the
+// strategies with which the objects of a test step can be applied to existing objects.
+// ApplyStrategyMerge, a JSON merge patch, is the default.
+const ApplyStrategyMerge = "Merge"
+const ApplyStrategyStrategicMerge = "StrategicMerge"
+const ApplyStrategyServerSide = "ServerSide"
+const ApplyStrategyReplace = "Replace"
+
+const ListMatchingStrict = "Strict"
+const ListMatchingUnordered = "Unordered"
+
+// ListMatchingAnnotation can be set on an expected object to choose how its lists are matched.
+// Valid values are ListMatchingStrict and ListMatchingUnordered.
+const ListMatchingAnnotation = "kuttl.dev/list-matching"
+
+// SemanticComparisonAnnotation can be set on an expected object to compare resource quantities, RFC 3339
+// timestamps and durations by value rather than textually. The value is either "true", for the whole object, or a comma-separated
+// list of field paths such as "spec.containers.resources,status.startTime".
+const SemanticComparisonAnnotation = "kuttl.dev/semantic-comparison"
+
+// CountAnnotation, MinCountAnnotation and MaxCountAnnotation can be set on an expected object without a name to
+// constrain the number of listed objects which match it.
+const CountAnnotation = "kuttl.dev/count"
+const MinCountAnnotation = "kuttl.dev/min-count"
+const MaxCountAnnotation = "kuttl.dev/max-count"
+
+// ExpectRejectionAnnotation can be set to "true" on an applied object to require the API server to reject it, e.g. in
+// tests of validating webhooks. ExpectRejectionCodeAnnotation and ExpectRejectionMessageAnnotation additionally
+// constrain the status code and the error message (a regular expression) of the rejection and imply the former.
+const ExpectRejectionAnnotation = "kuttl.dev/expect-rejection"
+const ExpectRejectionCodeAnnotation = "kuttl.dev/expect-rejection-code"
+const ExpectRejectionMessageAnnotation = "kuttl.dev/expect-rejection-message"
+
+// MatrixFile is the name of the file in the directory of a test case which declares its TestMatrix.
+const MatrixFile = "matrix.yaml"
+
 // Create embedded struct to implement custom DeepCopyInto method
 type RestConfig struct {
 	RC *rest.Config
@@ -25,6 +63,33 @@ type TestFile struct {
 
 	// Which test runs should this file be used in. Empty selector matches all test runs.
 	TestRunSelector *metav1.LabelSelector `json:"testRunSelector,omitempty"`
+
+	// Specifies how lists in the asserted objects of this file are matched: Strict (element by element, with equal
+	// lengths) or Unordered (each expected element must match a distinct actual element, extra actual elements are
+	// allowed). Defaults to Strict. Can be overridden per object by the kuttl.dev/list-matching annotation.
+	// +kubebuilder:validation:Enum=Strict;Unordered
+	ListMatching string `json:"listMatching,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TestMatrix parameterises a test case. It is declared in the file matrix.yaml in the directory of the test case,
+// which is run once for every combination of the values of its dimensions.
+type TestMatrix struct {
+	// The type meta object, should always be a GVK of kuttl.dev/v1beta1/TestMatrix.
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Dimensions are the variables of the test case and the values they take.
+	Dimensions []MatrixDimension `json:"dimensions"`
+}
+
+// MatrixDimension is a variable of a TestMatrix and the values it takes.
+type MatrixDimension struct {
+	// Name of the variable, which commands and expanded files can reference as $NAME.
+	Name string `json:"name"`
+	// Values of the variable.
+	Values []string `json:"values"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -52,6 +117,9 @@ type TestSuite struct {
 	// AttachControlPlaneOutput if true, attaches control plane logs (api-server, etcd) into stdout. This is useful for debugging.
 	// defaults to false
 	AttachControlPlaneOutput bool `json:"attachControlPlaneOutput"`
+	// Whether or not to run the tests without a Kubernetes cluster, against an in-memory object store which
+	// serves the built-in types and the CRDs in CRDDir. Implies that every test step is a unit test.
+	Offline bool `json:"offline"`
 	// Whether or not to start a local kind cluster for the tests.
 	StartKIND bool `json:"startKIND"`
 	// Path to the KIND configuration file to use.
@@ -91,10 +159,42 @@ type TestSuite struct {
 	Namespace string `json:"namespace"`
 	// Suppress is used to suppress logs
 	Suppress []string `json:"suppress"`
+	// SemanticComparison compares resource quantities, RFC 3339 timestamps and durations in asserts and errors by
+	// value rather than textually, e.g. "1Gi" equals "1024Mi".
+	SemanticComparison bool `json:"semanticComparison,omitempty"`
+	// ApplyStrategy is the default strategy with which the objects of the test steps are applied: Merge,
+	// StrategicMerge, ServerSide or Replace. Defaults to Merge.
+	ApplyStrategy string `json:"applyStrategy,omitempty"`
+	// FieldManager is the default field manager of server-side applies. Defaults to kuttl.
+	FieldManager string `json:"fieldManager,omitempty"`
+	// ForceConflicts makes server-side applies take ownership of fields managed by other field managers.
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+	// ExpandVariables expands $NAMESPACE and the variables of the test case in the files of all test steps.
+	ExpandVariables bool `json:"expandVariables,omitempty"`
+	// WatchCache re-evaluates the asserts of the test steps as soon as the objects they reference change, and
+	// serves their reads from informers in the namespaces of the tests.
+	WatchCache bool `json:"watchCache,omitempty"`
+
+	// BeforeAll is run once before all tests, after the CRDs, manifests and commands of the test suite.
+	BeforeAll *LifecycleHook `json:"beforeAll,omitempty"`
+	// AfterAll is run once after all tests, also if the test suite failed.
+	AfterAll *LifecycleHook `json:"afterAll,omitempty"`
+	// BeforeEach is run in the namespace of every test case before its steps. If it fails, the steps are skipped.
+	BeforeEach *LifecycleHook `json:"beforeEach,omitempty"`
+	// AfterEach is run in the namespace of every test case after its steps, also if they failed.
+	AfterEach *LifecycleHook `json:"afterEach,omitempty"`
 
 	Config *RestConfig `json:"config,omitempty"`
 }
 
+// LifecycleHook describes commands and test steps to run before or after the tests.
+type LifecycleHook struct {
+	// Commands to run. Only the commands of beforeAll can be run in the background.
+	Commands []Command `json:"commands,omitempty"`
+	// Directory containing test step files which are run like the steps of a test case, after the commands.
+	Steps string `json:"steps,omitempty"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // TestStep settings to apply to a test step.go
@@ -117,11 +217,18 @@ type TestStep struct {
 	// Objects to delete at the beginning of the test step.
 	Delete []ObjectReference `json:"delete,omitempty"`
 
+	// Patches to apply to existing objects after the objects of the test step have been applied.
+	Patch []TestPatch `json:"patch,omitempty"`
+
 	// Indicates that this is a unit test - safe to run without a real Kubernetes cluster.
+	// The step is run against an in-memory object store shared by the unit test steps of the test case.
 	UnitTest bool `json:"unitTest"`
 
 	// Commands to run prior at the beginning of the test step.
 	Commands []Command `json:"commands"`
+	// Env are environment variables of the commands of the test step. The environment variables of a command
+	// take precedence.
+	Env []EnvVar `json:"env,omitempty"`
 
 	// Allowed environment labels
 	// Disallowed environment labels
@@ -136,6 +243,26 @@ type TestStep struct {
 
 	// Specifies the context to use from the Kubeconfig.
 	Context string `json:"context,omitempty"`
+
+	// ApplyStrategy overrides the strategy of the test suite with which the objects of this step are applied.
+	// +kubebuilder:validation:Enum=Merge;StrategicMerge;ServerSide;Replace
+	ApplyStrategy string `json:"applyStrategy,omitempty"`
+	// FieldManager overrides the field manager of server-side applies.
+	FieldManager string `json:"fieldManager,omitempty"`
+	// ForceConflicts makes server-side applies take ownership of fields managed by other field managers.
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+
+	// ApplyStatus writes the status of the applied objects through the status subresource after they have been
+	// created or updated, e.g. to fake the status of dependent resources when no controller is running.
+	ApplyStatus bool `json:"applyStatus,omitempty"`
+
+	// Finally marks a cleanup step which runs after the other steps of the test case, also if one of them failed.
+	// Finally steps run in the order of their index and their failures are reported separately.
+	Finally bool `json:"finally,omitempty"`
+
+	// ExpandVariables expands $NAMESPACE and the variables of the test case in the files of this step, including the
+	// files referenced by apply, assert and error, when the step is run.
+	ExpandVariables bool `json:"expandVariables,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -148,10 +275,153 @@ type TestAssert struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Override the default timeout of 30 seconds (in seconds).
 	Timeout int `json:"timeout"`
+	// Consistently requires the asserted state, and the absence of the errors objects, to hold for this many seconds
+	// once it has been reached. The step fails if the state breaks during that window. The window is part of the
+	// timeout, so it must be shorter than the timeout.
+	Consistently int `json:"consistently,omitempty"`
+	// FailFast ends the step as soon as an object matching one of the errors objects is observed, instead of
+	// waiting for it to disappear until the timeout.
+	FailFast bool `json:"failFast,omitempty"`
 	// Collectors is a set of pod log collectors fired on an assert failure
 	Collectors []*TestCollector `json:"collectors,omitempty"`
 	// Commands is a set of commands to be run as assertions for the current step
 	Commands []TestAssertCommand `json:"commands,omitempty"`
+	// ResourceRefs are the resources made available to the CEL expressions in AssertAny and AssertAll.
+	ResourceRefs []TestResourceRef `json:"resourceRefs,omitempty"`
+	// AssertAny is a set of CEL expressions of which at least one must evaluate to true.
+	AssertAny []*Assertion `json:"assertAny,omitempty"`
+	// AssertAll is a set of CEL expressions which must all evaluate to true.
+	AssertAll []*Assertion `json:"assertAll,omitempty"`
+	// JSONPath is a set of JSONPath expressions which must evaluate to the expected values.
+	JSONPath []TestJSONPathAssert `json:"jsonPath,omitempty"`
+	// HTTP is a set of assertions on HTTP endpoints of services or pods, reached through the API server proxy.
+	HTTP []TestHTTPAssert `json:"http,omitempty"`
+	// Logs is a set of assertions on the logs of pods.
+	Logs []TestLogAssert `json:"logs,omitempty"`
+	// Events is a set of assertions on the events of the test namespace.
+	Events []TestEventAssert `json:"events,omitempty"`
+	// OrderedEvents requires the events matching the Events assertions to have been emitted in the order of the assertions.
+	OrderedEvents bool `json:"orderedEvents,omitempty"`
+}
+
+// TestJSONPathAssert asserts the result of a JSONPath expression evaluated against a resource.
+// Without a name, the resources of the kind matching the labels are listed and one of them must satisfy the assertion.
+type TestJSONPathAssert struct {
+	// The Kubernetes API version of the resource.
+	APIVersion string `json:"apiVersion"`
+	// The Kubernetes kind of the resource.
+	Kind string `json:"kind"`
+	// The namespace of the resource. The current test namespace will be used by default.
+	Namespace string `json:"namespace,omitempty"`
+	// The name of the resource.
+	Name string `json:"name,omitempty"`
+	// Labels to select the resources by if no name is set.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Path is a JSONPath template in the dialect of `kubectl get -o jsonpath`, e.g.
+	// {.status.containerStatuses[?(@.name=="sidecar")].ready}. The braces may be omitted for a single expression.
+	Path string `json:"path"`
+	// Value is the expected result, as `kubectl get -o jsonpath` would print it.
+	Value string `json:"value"`
+}
+
+// TestHTTPAssert asserts the response of an HTTP endpoint of a service or pod. The request is sent through the
+// proxy of the API server, so the endpoint does not need to be reachable from where kuttl runs.
+type TestHTTPAssert struct {
+	// Service is the name of the service to send the request to. Either service or pod is required.
+	Service string `json:"service,omitempty"`
+	// Pod is the name of the pod to send the request to.
+	Pod string `json:"pod,omitempty"`
+	// namespace to use. The current test namespace will be used by default.
+	Namespace string `json:"namespace,omitempty"`
+	// Port is the name or number of the service or container port.
+	Port intstr.IntOrString `json:"port"`
+	// Scheme is the scheme of the endpoint, http or https. Defaults to http.
+	Scheme string `json:"scheme,omitempty"`
+	// Method is the HTTP method of the request. Defaults to GET.
+	Method string `json:"method,omitempty"`
+	// Path is the path of the request, including an optional query string.
+	Path string `json:"path,omitempty"`
+	// StatusCode is the expected status code of the response. Defaults to 200.
+	StatusCode int `json:"statusCode,omitempty"`
+	// Headers are the expected header values of the response.
+	Headers map[string]string `json:"headers,omitempty"`
+	// BodyRegex is a regular expression which must match the body of the response.
+	BodyRegex string `json:"bodyRegex,omitempty"`
+	// JSONBody is a JSON value the body of the response must be a superset of, like asserted objects.
+	JSONBody *apiextv1.JSON `json:"jsonBody,omitempty"`
+}
+
+// TestEventAssert asserts that matching Kubernetes events have been emitted. Empty fields match any value.
+type TestEventAssert struct {
+	// Regarding selects the object the events are about.
+	Regarding EventObjectReference `json:"regarding,omitempty"`
+	// namespace to use. The current test namespace will be used by default.
+	Namespace string `json:"namespace,omitempty"`
+	// Reason is the reason of the events, e.g. InvalidSpec.
+	Reason string `json:"reason,omitempty"`
+	// Type is the type of the events, Normal or Warning.
+	Type string `json:"type,omitempty"`
+	// Note is a regular expression which must match the note (message) of the events.
+	Note string `json:"note,omitempty"`
+	// MinCount is the number of times matching events must have occurred. Defaults to 1.
+	MinCount int `json:"minCount,omitempty"`
+}
+
+// EventObjectReference identifies the object an event is about. Empty fields match any value.
+type EventObjectReference struct {
+	// The Kubernetes API version of the object.
+	APIVersion string `json:"apiVersion,omitempty"`
+	// The Kubernetes kind of the object.
+	Kind string `json:"kind,omitempty"`
+	// The name of the object.
+	Name string `json:"name,omitempty"`
+}
+
+// TestLogAssert asserts the content of the logs of the pods selected by name or label selector.
+// The positive conditions Contains and Regex pass if the logs of any selected container satisfy them,
+// the negative conditions NotContains and NotRegex only pass if no selected container's logs violate them.
+type TestLogAssert struct {
+	// The pod name to access logs.
+	Pod string `json:"pod,omitempty"`
+	// namespace to use. The current test namespace will be used by default.
+	Namespace string `json:"namespace,omitempty"`
+	// Container in pod to get logs from else all containers are used.
+	Container string `json:"container,omitempty"`
+	// Selector is a label query to select pods.
+	Selector string `json:"selector,omitempty"`
+	// Tail is the number of last lines to check. If omitted or zero,
+	// then the default is 10 if you use a selector, or -1 (all) if you use a pod name.
+	// This matches default behavior of `kubectl logs`.
+	Tail int `json:"tail,omitempty"`
+	// Contains is a string which must appear in the logs.
+	Contains string `json:"contains,omitempty"`
+	// Regex is a regular expression which must match the logs.
+	Regex string `json:"regex,omitempty"`
+	// NotContains is a string which must not appear in the logs.
+	NotContains string `json:"notContains,omitempty"`
+	// NotRegex is a regular expression which must not match the logs.
+	NotRegex string `json:"notRegex,omitempty"`
+}
+
+// TestResourceRef is a reference to a Kubernetes resource which is exposed to CEL expressions
+// under the identifier given in Ref.
+type TestResourceRef struct {
+	// The Kubernetes API version of the resource.
+	APIVersion string `json:"apiVersion"`
+	// The Kubernetes kind of the resource.
+	Kind string `json:"kind"`
+	// The namespace of the resource. The current test namespace will be used by default.
+	Namespace string `json:"namespace,omitempty"`
+	// The name of the resource.
+	Name string `json:"name"`
+	// Ref is the identifier by which the resource can be accessed in CEL expressions.
+	Ref string `json:"ref"`
+}
+
+// Assertion is a single CEL expression that is expected to evaluate to true.
+type Assertion struct {
+	// CELExpression is the CEL expression to evaluate.
+	CELExpression string `json:"celExpr"`
 }
 
 // TestAssertCommand an assertion based on the result of the execution of a command
@@ -176,6 +446,18 @@ type ObjectReference struct {
 	Labels map[string]string `json:"labels"`
 }
 
+// TestPatch patches the objects an object reference refers to. Exactly one of JSONPatch, MergePatch and
+// StrategicMergePatch must be set.
+type TestPatch struct {
+	ObjectReference `json:",inline"`
+	// JSONPatch is a list of RFC 6902 JSON patch operations.
+	JSONPatch *apiextv1.JSON `json:"jsonPatch,omitempty"`
+	// MergePatch is an RFC 7386 JSON merge patch.
+	MergePatch *apiextv1.JSON `json:"mergePatch,omitempty"`
+	// StrategicMergePatch is a Kubernetes strategic merge patch.
+	StrategicMergePatch *apiextv1.JSON `json:"strategicMergePatch,omitempty"`
+}
+
 // Command describes a command to run as a part of a test step or suite.
 type Command struct {
 	// The command and argument to run as a string.
@@ -194,6 +476,56 @@ type Command struct {
 	Timeout int `json:"timeout"`
 	// If set, the output from the command is NOT logged.  Useful for sensitive logs or to reduce noise.
 	SkipLogOutput bool `json:"skipLogOutput"`
+	// Output captures the standard output of the command in a variable, which is passed to the later commands of the
+	// test case. Can not be set for background commands.
+	Output *CommandOutput `json:"output,omitempty"`
+	// Env are environment variables of the command. They are also expanded in Command.
+	Env []EnvVar `json:"env,omitempty"`
+}
+
+// EnvVar is an environment variable of commands. Its value is either set literally or read from a source.
+type EnvVar struct {
+	// Name of the environment variable.
+	Name string `json:"name"`
+	// Value of the environment variable.
+	Value string `json:"value,omitempty"`
+	// ValueFrom is the source of the value of the environment variable.
+	ValueFrom *EnvVarSource `json:"valueFrom,omitempty"`
+}
+
+// EnvVarSource is the source of the value of an EnvVar. Exactly one of its fields must be set.
+type EnvVarSource struct {
+	// SecretKeyRef selects a key of a Secret in the namespace of the command. Values read from Secrets are masked
+	// in the logs.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ConfigMapKeyRef selects a key of a ConfigMap in the namespace of the command.
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// File is the path of a local file whose contents are the value. Relative paths are relative to the directory
+	// of the test step.
+	File string `json:"file,omitempty"`
+}
+
+// CommandOutput stores the standard output of a command, or a value extracted from it or from an object, in a variable.
+type CommandOutput struct {
+	// Name of the variable, which later commands can reference as $NAME.
+	Name string `json:"name"`
+	// JSONPath is evaluated against the standard output parsed as JSON, e.g. of `kubectl get -o json`, or against
+	// Object to extract the value, in the dialect of `kubectl get -o jsonpath`. Without it, the whole output is
+	// stored, without trailing newlines.
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Object is fetched after the command has run and is the source of the value instead of the standard output.
+	// It requires JSONPath.
+	Object *OutputObjectReference `json:"object,omitempty"`
+}
+
+// OutputObjectReference references the object a CommandOutput is read from. The name and namespace can reference
+// the variables of the command, e.g. $NAMESPACE.
+type OutputObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Namespace of the object, which defaults to the namespace of the command for namespaced objects.
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // TestCollector are post assert / error commands that allow for the collection of information sent to the test log.
@@ -0,0 +1,182 @@
+package expressions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// Program is a compiled CEL assertion.
+type Program struct {
+	ast     *cel.Ast
+	program cel.Program
+}
+
+// BuildEnv creates a CEL environment declaring one variable per resource reference.
+func BuildEnv(resourceRefs []harness.TestResourceRef) (*cel.Env, error) {
+	opts := []cel.EnvOption{cel.CrossTypeNumericComparisons(true)}
+	seen := map[string]bool{}
+
+	for i := range resourceRefs {
+		ref := &resourceRefs[i]
+		if err := ref.Validate(); err != nil {
+			return nil, fmt.Errorf("resource ref %d: %w", i, err)
+		}
+		if seen[ref.Ref] {
+			return nil, fmt.Errorf("resource ref %q is declared more than once", ref.Ref)
+		}
+		seen[ref.Ref] = true
+		opts = append(opts, cel.Variable(ref.Ref, cel.MapType(cel.StringType, cel.DynType)))
+	}
+
+	return cel.NewEnv(opts...)
+}
+
+// LoadPrograms compiles all CEL expressions of a TestAssert, keyed by the expression.
+// It returns nil if the TestAssert has no expressions.
+func LoadPrograms(testAssert *harness.TestAssert) (map[string]*Program, error) {
+	if testAssert == nil || (len(testAssert.AssertAny) == 0 && len(testAssert.AssertAll) == 0) {
+		return nil, nil
+	}
+
+	env, err := BuildEnv(testAssert.ResourceRefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	programs := map[string]*Program{}
+
+	var errs []error
+	for _, assertion := range append(append([]*harness.Assertion{}, testAssert.AssertAny...), testAssert.AssertAll...) {
+		if _, ok := programs[assertion.CELExpression]; ok {
+			continue
+		}
+		prg, err := compile(env, assertion.CELExpression)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		programs[assertion.CELExpression] = prg
+	}
+
+	return programs, errors.Join(errs...)
+}
+
+func compile(env *cel.Env, expr string) (*Program, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("type-check error in %q: %s", expr, issues.Err())
+	}
+
+	if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression %q must evaluate to bool, not %s", expr, ast.OutputType())
+	}
+
+	prg, err := env.Program(ast, cel.EvalOptions(cel.OptTrackState))
+	if err != nil {
+		return nil, fmt.Errorf("program construction error in %q: %w", expr, err)
+	}
+
+	return &Program{ast: ast, program: prg}, nil
+}
+
+// Evaluate runs the program against variables and returns an error describing the evaluated
+// operands unless the expression evaluates to true.
+func (p *Program) Evaluate(variables map[string]interface{}) error {
+	out, details, err := p.program.Eval(variables)
+	if err != nil {
+		return err
+	}
+
+	if out == types.True {
+		return nil
+	}
+
+	result := fmt.Sprintf("evaluated to %v", out.Value())
+	if out.Type() != types.BoolType {
+		result = fmt.Sprintf("evaluated to non-boolean %s %v", out.Type().TypeName(), out.Value())
+	}
+
+	if operands := p.operands(details); operands != "" {
+		result = fmt.Sprintf("%s (%s)", result, operands)
+	}
+	return errors.New(result)
+}
+
+// operands describes the values of the non-literal arguments of the top-level function call,
+// e.g. the left and right hand side of a comparison.
+func (p *Program) operands(details *cel.EvalDetails) string {
+	if details == nil || details.State() == nil {
+		return ""
+	}
+
+	native := p.ast.NativeRep()
+	root := native.Expr()
+	if root.Kind() != celast.CallKind {
+		return ""
+	}
+
+	var described []string
+	for _, arg := range root.AsCall().Args() {
+		if arg.Kind() == celast.LiteralKind {
+			continue
+		}
+		text, err := parser.Unparse(arg, native.SourceInfo())
+		if err != nil {
+			continue
+		}
+		val, found := details.State().Value(arg.ID())
+		if !found {
+			continue
+		}
+		described = append(described, fmt.Sprintf("%s = %v", text, val))
+	}
+
+	return strings.Join(described, ", ")
+}
+
+// RunAssertExpressions evaluates the assertAny and assertAll expressions against the variables,
+// returning an error for every failed assertion.
+func RunAssertExpressions(programs map[string]*Program, variables map[string]interface{}, assertAny, assertAll []*harness.Assertion) []error {
+	var errs []error
+
+	var anyErrs []error
+	for _, assertion := range assertAny {
+		if err := evaluate(programs, variables, assertion); err != nil {
+			anyErrs = append(anyErrs, err)
+			continue
+		}
+		// one passing expression is enough
+		anyErrs = nil
+		break
+	}
+	if len(anyErrs) > 0 {
+		errs = append(errs, fmt.Errorf("none of the assertAny expressions evaluated to true: %w", errors.Join(anyErrs...)))
+	}
+
+	for _, assertion := range assertAll {
+		if err := evaluate(programs, variables, assertion); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func evaluate(programs map[string]*Program, variables map[string]interface{}, assertion *harness.Assertion) error {
+	prg, ok := programs[assertion.CELExpression]
+	if !ok {
+		return fmt.Errorf("expression %q was not compiled", assertion.CELExpression)
+	}
+	if err := prg.Evaluate(variables); err != nil {
+		return fmt.Errorf("assertion %q failed: %w", assertion.CELExpression, err)
+	}
+	return nil
+}
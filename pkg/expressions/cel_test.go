@@ -0,0 +1,84 @@
+package expressions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+func TestLoadPrograms(t *testing.T) {
+	refs := []harness.TestResourceRef{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "coredns", Ref: "coredns"},
+	}
+
+	programs, err := LoadPrograms(&harness.TestAssert{
+		ResourceRefs: refs,
+		AssertAll:    []*harness.Assertion{{CELExpression: "coredns.status.readyReplicas > 1"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, programs, 1)
+
+	_, err = LoadPrograms(&harness.TestAssert{
+		ResourceRefs: refs,
+		AssertAll:    []*harness.Assertion{{CELExpression: "unknown.status.readyReplicas > 1"}},
+	})
+	assert.ErrorContains(t, err, "undeclared reference to 'unknown'")
+
+	_, err = LoadPrograms(&harness.TestAssert{
+		ResourceRefs: refs,
+		AssertAny:    []*harness.Assertion{{CELExpression: "'not a bool'"}},
+	})
+	assert.ErrorContains(t, err, "must evaluate to bool")
+
+	_, err = LoadPrograms(&harness.TestAssert{
+		ResourceRefs: append(refs, refs[0]),
+		AssertAll:    []*harness.Assertion{{CELExpression: "true"}},
+	})
+	assert.ErrorContains(t, err, `resource ref "coredns" is declared more than once`)
+}
+
+func TestRunAssertExpressions(t *testing.T) {
+	testAssert := &harness.TestAssert{
+		ResourceRefs: []harness.TestResourceRef{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "coredns", Ref: "coredns"},
+		},
+		AssertAny: []*harness.Assertion{
+			{CELExpression: "coredns.status.readyReplicas > 5"},
+			{CELExpression: "coredns.status.readyReplicas == 2"},
+		},
+		AssertAll: []*harness.Assertion{
+			{CELExpression: "coredns.status.readyReplicas >= 2"},
+			{CELExpression: "coredns.spec.replicas == coredns.status.readyReplicas"},
+		},
+	}
+	programs, err := LoadPrograms(testAssert)
+	require.NoError(t, err)
+
+	deployment := func(replicas, ready int64) map[string]interface{} {
+		return map[string]interface{}{
+			"coredns": map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": replicas},
+				"status": map[string]interface{}{"readyReplicas": ready},
+			},
+		}
+	}
+
+	assert.Empty(t, RunAssertExpressions(programs, deployment(2, 2), testAssert.AssertAny, testAssert.AssertAll))
+
+	errs := RunAssertExpressions(programs, deployment(3, 2), testAssert.AssertAny, testAssert.AssertAll)
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], `assertion "coredns.spec.replicas == coredns.status.readyReplicas" failed: evaluated to false (coredns.spec.replicas = 3, coredns.status.readyReplicas = 2)`)
+
+	errs = RunAssertExpressions(programs, deployment(1, 1), testAssert.AssertAny, testAssert.AssertAll)
+	require.Len(t, errs, 2)
+	assert.ErrorContains(t, errs[0], "none of the assertAny expressions evaluated to true")
+	assert.ErrorContains(t, errs[0], `assertion "coredns.status.readyReplicas == 2" failed: evaluated to false (coredns.status.readyReplicas = 1)`)
+	assert.EqualError(t, errs[1], `assertion "coredns.status.readyReplicas >= 2" failed: evaluated to false (coredns.status.readyReplicas = 1)`)
+
+	errs = RunAssertExpressions(programs, map[string]interface{}{"coredns": map[string]interface{}{}}, nil, testAssert.AssertAll)
+	require.Len(t, errs, 2)
+	assert.ErrorContains(t, errs[0], "no such key: status")
+}
@@ -13,10 +13,16 @@ import (
 
 // from a list of paths, returns an array of runtime objects
 func ToObjects(paths []string) ([]client.Object, error) {
+	return ToObjectsWithVariables(paths, nil)
+}
+
+// ToObjectsWithVariables is like ToObjects, but expands the variables in the files with
+// testutils.LoadExpandedYAMLFromFile unless they are nil.
+func ToObjectsWithVariables(paths []string, variables map[string]string) ([]client.Object, error) {
 	apply := []client.Object{}
 
 	for _, path := range paths {
-		objs, err := testutils.LoadYAMLFromFile(path)
+		objs, err := testutils.LoadExpandedYAMLFromFile(path, variables)
 		if err != nil {
 			return nil, fmt.Errorf("file %q load yaml error: %w", path, err)
 		}
@@ -1,9 +1,12 @@
 package file
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFromPath(t *testing.T) {
@@ -60,6 +63,26 @@ func TestToRuntimeObjects(t *testing.T) {
 	assert.Error(t, err, "file \"testdata/path/test2.yaml\" load yaml error")
 }
 
+func TestToObjectsWithVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pod.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: $NAME
+  namespace: $$NAMESPACE
+`), 0600))
+
+	objs, err := ToObjectsWithVariables([]string{path}, map[string]string{"NAME": "hello"})
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, "hello", objs[0].GetName())
+	assert.Equal(t, "$NAMESPACE", objs[0].GetNamespace())
+
+	objs, err = ToObjects([]string{path})
+	require.NoError(t, err)
+	assert.Equal(t, "$NAME", objs[0].GetName())
+}
+
 func TestTrimExt(t *testing.T) {
 	tests := []struct {
 		name     string
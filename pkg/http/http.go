@@ -19,6 +19,12 @@ func IsURL(str string) bool {
 // ToObjects takes a url, pulls the file and returns  []runtime.Object
 // url must be a full path to a manifest file.  that file can have multiple runtime objects.
 func ToObjects(urlPath string) ([]client.Object, error) {
+	return ToObjectsWithVariables(urlPath, nil)
+}
+
+// ToObjectsWithVariables is like ToObjects, but expands the variables in the file with testutils.LoadExpandedYAML
+// unless they are nil.
+func ToObjectsWithVariables(urlPath string, variables map[string]string) ([]client.Object, error) {
 	apply := []client.Object{}
 
 	buf, err := Read(urlPath)
@@ -26,7 +32,7 @@ func ToObjects(urlPath string) ([]client.Object, error) {
 		return nil, err
 	}
 
-	objs, err := testutils.LoadYAML(urlPath, buf)
+	objs, err := testutils.LoadExpandedYAML(urlPath, buf, variables)
 	if err != nil {
 		return nil, fmt.Errorf("url %q load yaml error: %w", urlPath, err)
 	}
@@ -10,13 +10,17 @@ import (
 
 var (
 	errorsExample = `  # Asserts "errors" against a $KUBECONFIG cluster the values defined in the assert file.
-  kubectl kuttl errors <path/to/errorsfile.yaml> <path/to/errorsfile.yaml>...`
+  kubectl kuttl errors <path/to/errorsfile.yaml> <path/to/errorsfile.yaml>...
+
+  # Fails immediately if a resource matching the errors file exists, instead of waiting for it to disappear.
+  kubectl kuttl errors --fail-fast <path/to/errorsfile.yaml>`
 )
 
 // newErrorsCmd returns a new initialized instance of the errors sub command
 func newErrorsCmd() *cobra.Command {
 	timeout := 5
 	namespace := "default"
+	failFast := false
 
 	errorsCmd := &cobra.Command{
 		Use:     "errors",
@@ -27,11 +31,12 @@ func newErrorsCmd() *cobra.Command {
 			if len(args) == 0 {
 				return errors.New("one file argument is required")
 			}
-			return test.Errors(namespace, timeout, args...)
+			return test.ErrorsWithOptions(namespace, timeout, test.ErrorsOptions{FailFast: failFast}, args...)
 		},
 	}
 
 	errorsCmd.Flags().IntVar(&timeout, "timeout", 5, "The timeout to use as default for error evaluation.")
 	errorsCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "Namespace to use for test errors.")
+	errorsCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Fail as soon as a resource matching the errors is observed, instead of waiting for it to disappear.")
 	return errorsCmd
 }
@@ -34,6 +34,9 @@ var (
   Run a Kubernetes control plane and install manifests and CRDs for the running tests:
     kubectl kuttl test --start-control-plane  --crd-dir ./config/crds/ --manifests-dir ./test/manifests/ ./test/integration/
 
+  Run tests without a Kubernetes cluster, against an in-memory object store serving the CRDs:
+    kubectl kuttl test --offline --crd-dir ./config/crds/ ./test/unit/
+
   Run tests against an existing Kubernetes cluster with a JUnit XML file output:
     kubectl kuttl test ./test/integration/ --report xml
 `
@@ -48,6 +51,7 @@ func newTestCmd() *cobra.Command { //nolint:gocyclo
 	startControlPlane := false
 	attachControlPlaneOutput := false
 	startKIND := false
+	offline := false
 	kindConfig := ""
 	kindContext := ""
 	skipDelete := false
@@ -61,6 +65,7 @@ func newTestCmd() *cobra.Command { //nolint:gocyclo
 	reportName := "kuttl-report"
 	namespace := ""
 	suppress := []string{}
+	watchCache := false
 	var runLabels labelSetValue
 
 	options := harness.TestSuite{}
@@ -141,6 +146,10 @@ For more detailed documentation, visit: https://kuttl.dev`,
 				options.KINDContext = kindContext
 			}
 
+			if isSet(flags, "offline") {
+				options.Offline = offline
+			}
+
 			if options.KINDContext == "" {
 				options.KINDContext = harness.DefaultKINDContext
 			}
@@ -149,6 +158,10 @@ For more detailed documentation, visit: https://kuttl.dev`,
 				return errors.New("only one of --start-control-plane and --start-kind can be set")
 			}
 
+			if options.Offline && (options.StartControlPlane || options.StartKIND) {
+				return errors.New("--offline can not be used with --start-control-plane or --start-kind")
+			}
+
 			// after control-plane && start=kind check
 			if options.AttachControlPlaneOutput && !options.StartControlPlane {
 				return errors.New("only use --attach-control-plane-output with --start-control-plane")
@@ -211,6 +224,10 @@ For more detailed documentation, visit: https://kuttl.dev`,
 				options.Timeout = timeout
 			}
 
+			if isSet(flags, "watch-cache") {
+				options.WatchCache = watchCache
+			}
+
 			if len(args) != 0 {
 				log.Println("kutt-test config testdirs is overridden with args: [", strings.Join(args, ", "), "]")
 				options.TestDirs = args
@@ -247,6 +264,7 @@ For more detailed documentation, visit: https://kuttl.dev`,
 	// TODO: remove after v0.16.0 deprecated mockControllerFile is not supported in the latest testenv
 	testCmd.Flags().StringVar(&mockControllerFile, "control-plane-config", "", "Path to file to load controller-runtime APIServer configuration arguments (only useful when --startControlPlane).")
 	testCmd.Flags().BoolVar(&startKIND, "start-kind", false, "Start a KIND cluster for the tests (cannot be used with --start-control-plane).")
+	testCmd.Flags().BoolVar(&offline, "offline", false, "Run the tests without a Kubernetes cluster, against an in-memory object store (cannot be used with --start-control-plane or --start-kind).")
 	testCmd.Flags().StringVar(&kindConfig, "kind-config", "", "Specify the KIND configuration file path (implies --start-kind, cannot be used with --start-control-plane).")
 	testCmd.Flags().StringVar(&kindContext, "kind-context", "", "Specify the KIND context name to use (default: kind).")
 	testCmd.Flags().StringVar(&artifactsDir, "artifacts-dir", "", "Directory to output kind logs to (if not specified, the current working directory).")
@@ -260,6 +278,7 @@ For more detailed documentation, visit: https://kuttl.dev`,
 	testCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace to use for tests. Provided namespaces must exist prior to running tests.")
 	testCmd.Flags().StringSliceVar(&suppress, "suppress-log", []string{}, "Suppress logging for these kinds of logs (events).")
 	testCmd.Flags().Var(&runLabels, "test-run-labels", "Labels to use for this test run.")
+	testCmd.Flags().BoolVar(&watchCache, "watch-cache", false, "Re-evaluate asserts as soon as the objects they reference change, reading them from informers.")
 	// This cannot be a global flag because pkg/test/utils.RunTests calls flag.Parse which barfs on unknown top-level flags.
 	// Putting it here at least does not advertise it on a level where using it is impossible.
 	test.SetFlags(testCmd.Flags())
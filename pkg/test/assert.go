@@ -59,6 +59,18 @@ func Assert(namespace string, timeout int, assertFiles ...string) error {
 
 // Errors checks all provided errors files against a namespace.  Upon assert failure, it prints the failures and returns an error
 func Errors(namespace string, timeout int, errorFiles ...string) error {
+	return ErrorsWithOptions(namespace, timeout, ErrorsOptions{}, errorFiles...)
+}
+
+// ErrorsOptions configure how ErrorsWithOptions checks the errors files.
+type ErrorsOptions struct {
+	// FailFast fails as soon as an object matching an errors object is observed, instead of waiting for it to
+	// disappear.
+	FailFast bool
+}
+
+// ErrorsWithOptions is like Errors, but configured by opts.
+func ErrorsWithOptions(namespace string, timeout int, opts ErrorsOptions, errorFiles ...string) error {
 	var objects []client.Object
 
 	for _, file := range errorFiles {
@@ -76,6 +88,25 @@ func Errors(namespace string, timeout int, errorFiles ...string) error {
 		DiscoveryClient: DiscoveryClient,
 	}
 
+	testErrors, forbidden := s.checkErrors(namespace, timeout, opts.FailFast, objects)
+	if len(testErrors) == 0 {
+		fmt.Printf("error assert is valid\n")
+		return nil
+	}
+
+	for _, testError := range testErrors {
+		fmt.Println(testError)
+	}
+	if forbidden {
+		return errors.New("forbidden state observed")
+	}
+	return errors.New("error asserts not valid")
+}
+
+// checkErrors checks every second for up to timeout seconds that none of the errors objects exist and returns the
+// errors of the last check. If failFast is set, it returns the forbidden states as soon as one is observed and
+// reports that by returning true.
+func (s *Step) checkErrors(namespace string, timeout int, failFast bool, objects []client.Object) ([]error, bool) {
 	var testErrors []error
 	for i := 0; i < timeout; i++ {
 		// start fresh
@@ -90,18 +121,15 @@ func Errors(namespace string, timeout int, errorFiles ...string) error {
 			break
 		}
 
-		time.Sleep(time.Second)
-	}
-
-	if len(testErrors) == 0 {
-		fmt.Printf("error assert is valid\n")
-		return nil
-	}
+		if failFast {
+			if forbidden := forbiddenStates(testErrors); len(forbidden) > 0 {
+				return forbidden, true
+			}
+		}
 
-	for _, testError := range testErrors {
-		fmt.Println(testError)
+		time.Sleep(time.Second)
 	}
-	return errors.New("error asserts not valid")
+	return testErrors, false
 }
 
 func Client(_ bool) (client.Client, error) {
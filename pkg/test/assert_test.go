@@ -0,0 +1,67 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+func TestCheckErrors(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		testutils.WithNamespace(testutils.NewPod("hello", ""), testNamespace),
+		testutils.WithNamespace(testutils.NewPod("world", ""), testNamespace),
+	).Build()
+
+	s := &Step{
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	t.Run("fail fast", func(t *testing.T) {
+		objects := []client.Object{
+			testutils.NewPod("missing", ""),
+			testutils.NewPod("hello", ""),
+		}
+
+		start := time.Now()
+		testErrors, forbidden := s.checkErrors(testNamespace, 10, true, objects)
+
+		assert.Less(t, time.Since(start), time.Second)
+		assert.True(t, forbidden)
+		require.Len(t, testErrors, 2)
+		assert.Contains(t, testErrors[0].Error(), "name: hello")
+		assert.EqualError(t, testErrors[1], "forbidden state observed: resource /v1, Kind=Pod hello matched error assertion")
+	})
+
+	t.Run("wait for timeout", func(t *testing.T) {
+		objects := []client.Object{
+			testutils.NewPod("hello", ""),
+			testutils.NewPod("missing", ""),
+			testutils.NewPod("world", ""),
+		}
+
+		start := time.Now()
+		testErrors, forbidden := s.checkErrors(testNamespace, 2, false, objects)
+
+		assert.GreaterOrEqual(t, time.Since(start), 2*time.Second)
+		assert.False(t, forbidden)
+		require.Len(t, testErrors, 2)
+		assert.EqualError(t, testErrors[0], "resource /v1, Kind=Pod hello matched error assertion")
+		assert.EqualError(t, testErrors[1], "resource /v1, Kind=Pod world matched error assertion")
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		testErrors, forbidden := s.checkErrors(testNamespace, 2, true, []client.Object{testutils.NewPod("missing", "")})
+
+		assert.False(t, forbidden)
+		assert.Empty(t, testErrors)
+	})
+}
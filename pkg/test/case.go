@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -22,6 +23,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
@@ -43,9 +45,38 @@ type Case struct {
 	Timeout            int
 	PreferredNamespace string
 	RunLabels          labels.Set
+	// Suite is the name of the test suite.
+	Suite string
+	// SuiteDir is the test directory the test case was loaded from.
+	SuiteDir string
+	// ArtifactsDir is the artifacts directory of the test suite, the current working directory if empty.
+	ArtifactsDir string
+	// SemanticComparison is passed on to the steps.
+	SemanticComparison bool
+	// ApplyOptions are passed on to the steps.
+	ApplyOptions testutils.ApplyOptions
+	// Offline runs all steps as unit tests.
+	Offline bool
+	// BeforeEach and AfterEach are the lifecycle hooks run before and after the steps.
+	BeforeEach *v1beta1.LifecycleHook
+	AfterEach  *v1beta1.LifecycleHook
+	// ExpandVariables is passed on to the steps.
+	ExpandVariables bool
+	// Variables are the variables of the test suite, e.g. the outputs of its commands. The test case passes a copy
+	// of them to its commands, which add the variables of their outputs to it.
+	Variables map[string]string
+	variables map[string]string
 
 	Client          func(forceNew bool) (client.Client, error)
 	DiscoveryClient func() (discovery.DiscoveryInterface, error)
+	// KubernetesClient is passed on to the steps.
+	KubernetesClient func() (kubernetes.Interface, error)
+	// WatchCache is passed on to the steps which use Client.
+	WatchCache *testutils.WatchCache
+	// OfflineCluster returns the in-memory cluster to run unit test steps against. It is called at most once per
+	// test case. If nil, unit test steps run against an empty cluster serving only the built-in types.
+	OfflineCluster func() (*testutils.OfflineCluster, error)
+	offline        *testutils.OfflineCluster
 
 	Logger testutils.Logger
 	// Suppress is used to suppress logs
@@ -336,7 +367,7 @@ func (t *Case) Run(test *testing.T, ts *report.Testsuite) {
 	clients := map[string]client.Client{"": cl}
 
 	for _, testStep := range t.Steps {
-		if clients[testStep.Kubeconfig] != nil || testStep.KubeconfigLoading == v1beta1.KubeconfigLoadingLazy {
+		if clients[testStep.Kubeconfig] != nil || testStep.KubeconfigLoading == v1beta1.KubeconfigLoadingLazy || t.isUnitTest(testStep) {
 			continue
 		}
 
@@ -361,59 +392,176 @@ func (t *Case) Run(test *testing.T, ts *report.Testsuite) {
 	}
 	ts.AddTestcase(setupReport)
 
+	t.variables = make(map[string]string, len(t.Variables))
+	for key, value := range t.Variables {
+		t.variables[key] = value
+	}
+
+	testSteps, finallySteps := []*Step{}, []*Step{}
 	for _, testStep := range t.Steps {
-		tc := report.NewCase("step " + testStep.String())
-		testStep.Client = t.Client
-		if testStep.Kubeconfig != "" {
-			testStep.Client = newClient(testStep.Kubeconfig, testStep.Context)
+		if isFinally(testStep) {
+			finallySteps = append(finallySteps, testStep)
+		} else {
+			testSteps = append(testSteps, testStep)
 		}
-		testStep.DiscoveryClient = t.DiscoveryClient
-		if testStep.Kubeconfig != "" {
-			testStep.DiscoveryClient = newDiscoveryClient(testStep.Kubeconfig, testStep.Context)
+	}
+
+	if !t.reportHook(test, ts, "beforeEach", t.BeforeEach, ns) {
+		// the steps are skipped, but the finally steps and afterEach are still run
+		testSteps = nil
+	}
+
+	for _, testStep := range testSteps {
+		if !t.runStep(test, ts, "step", testStep, ns) {
+			break
 		}
-		testStep.Logger = t.Logger.WithPrefix(testStep.String())
-		tc.Assertions += len(testStep.Asserts)
-		tc.Assertions += len(testStep.Errors)
+	}
 
-		errs := []error{}
+	// finally steps run after the other steps, also if one of them failed, and all of them are run
+	for _, testStep := range finallySteps {
+		t.runStep(test, ts, "finally step", testStep, ns)
+	}
 
-		// Set-up client/namespace for lazy-loaded Kubeconfig
-		if testStep.KubeconfigLoading == v1beta1.KubeconfigLoadingLazy {
-			cl, err = testStep.Client(false)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("failed to lazy-load kubeconfig: %w", err))
-			} else if err = t.CreateNamespace(test, cl, ns); k8serrors.IsAlreadyExists(err) {
-				t.Logger.Logf("namespace %q already exists", ns.Name)
-			} else if err != nil {
-				errs = append(errs, fmt.Errorf("failed to create test namespace: %w", err))
-			}
+	t.reportHook(test, ts, "afterEach", t.AfterEach, ns)
+
+	if funk.Contains(t.Suppress, "events") {
+		t.Logger.Logf("skipping kubernetes event logging")
+	} else {
+		t.CollectEvents(ns.Name)
+	}
+}
+
+// runStep sets up and runs testStep, adding its outcome to ts as a testcase. The kind of step, "step" or
+// "finally step", prefixes the name of the testcase and the failure. It returns false if the step failed.
+func (t *Case) runStep(test *testing.T, ts *report.Testsuite, kind string, testStep *Step, ns *namespace) bool {
+	tc := report.NewCase(kind + " " + testStep.String())
+	testStep.Logger = t.Logger.WithPrefix(testStep.String())
+	tc.Assertions += len(testStep.Asserts)
+	tc.Assertions += len(testStep.Errors)
+	if testStep.Assert != nil {
+		tc.Assertions += len(testStep.Assert.AssertAny) + len(testStep.Assert.AssertAll) + len(testStep.Assert.JSONPath)
+		tc.Assertions += len(testStep.Assert.HTTP) + len(testStep.Assert.Logs) + len(testStep.Assert.Events)
+	}
+
+	errs := t.setupStep(test, testStep, ns)
+
+	// Run test case only if no setup errors are encountered
+	if len(errs) == 0 {
+		errs = append(errs, testStep.Run(test, ns.Name)...)
+	}
+
+	if len(errs) > 0 {
+		caseErr := fmt.Errorf("failed in %s %s", kind, testStep.String())
+		// report every error, e.g. the mismatches of all asserted objects, but not the diffs
+		failures := withoutDiffs(errs)
+		if len(failures) == 0 {
+			failures = errs
 		}
+		tc.Failure = report.NewFailure(caseErr.Error(), []error{errors.Join(failures...)})
 
-		// Run test case only if no setup errors are encountered
-		if len(errs) == 0 {
-			errs = append(errs, testStep.Run(test, ns.Name)...)
+		test.Error(caseErr)
+		for _, err := range errs {
+			test.Error(err)
 		}
+	}
+	ts.AddTestcase(tc)
 
-		if len(errs) > 0 {
-			caseErr := fmt.Errorf("failed in step %s", testStep.String())
-			tc.Failure = report.NewFailure(caseErr.Error(), errs)
+	return len(errs) == 0
+}
 
-			test.Error(caseErr)
-			for _, err := range errs {
-				test.Error(err)
-			}
+// setupStep sets the clients of testStep. For unit tests, the in-memory cluster of the test case is set up, and for
+// a lazy-loaded Kubeconfig the test namespace is created.
+func (t *Case) setupStep(test *testing.T, testStep *Step, ns *namespace) []error {
+	testStep.Client = t.Client
+	testStep.WatchCache = t.WatchCache
+	testStep.Variables = t.variables
+	testStep.TestInfo = t.testInfo()
+	if testStep.Kubeconfig != "" {
+		testStep.Client = newClient(testStep.Kubeconfig, testStep.Context)
+		testStep.WatchCache = nil
+	}
+	testStep.DiscoveryClient = t.DiscoveryClient
+	if testStep.Kubeconfig != "" {
+		testStep.DiscoveryClient = newDiscoveryClient(testStep.Kubeconfig, testStep.Context)
+	}
+	testStep.KubernetesClient = t.KubernetesClient
+	if testStep.Kubeconfig != "" {
+		testStep.KubernetesClient = newKubernetesClient(testStep.Kubeconfig, testStep.Context)
+	}
+
+	errs := []error{}
+
+	if testStep.ExpandVariables {
+		if err := testStep.Expand(ns.Name); err != nil {
+			return append(errs, fmt.Errorf("failed to expand variables: %w", err))
 		}
-		ts.AddTestcase(tc)
-		if len(errs) > 0 {
-			break
+	}
+
+	if t.isUnitTest(testStep) {
+		// Set-up client/namespace for unit tests
+		offline, err := t.offlineCluster(test, ns)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to set up in-memory cluster: %w", err))
+		} else {
+			testStep.Client = offline.Client
+			testStep.DiscoveryClient = offline.DiscoveryClient
+			testStep.KubernetesClient = offline.KubernetesClient
+			testStep.WatchCache = nil
+		}
+	} else if testStep.KubeconfigLoading == v1beta1.KubeconfigLoadingLazy {
+		// Set-up client/namespace for lazy-loaded Kubeconfig
+		cl, err := testStep.Client(false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to lazy-load kubeconfig: %w", err))
+		} else if err = t.CreateNamespace(test, cl, ns); k8serrors.IsAlreadyExists(err) {
+			t.Logger.Logf("namespace %q already exists", ns.Name)
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create test namespace: %w", err))
 		}
 	}
 
-	if funk.Contains(t.Suppress, "events") {
-		t.Logger.Logf("skipping kubernetes event logging")
-	} else {
-		t.CollectEvents(ns.Name)
+	return errs
+}
+
+// isFinally returns true if testStep is a finally step, which is run after the other steps of the test case even
+// if one of them failed.
+func isFinally(testStep *Step) bool {
+	return testStep.Step != nil && testStep.Step.Finally
+}
+
+// isUnitTest returns true if testStep runs against the in-memory cluster of the test case.
+func (t *Case) isUnitTest(testStep *Step) bool {
+	return t.Offline || (testStep.Step != nil && testStep.Step.UnitTest)
+}
+
+// offlineCluster returns the in-memory cluster shared by the unit test steps of the test case, creating it and the
+// test namespace in it on first use.
+func (t *Case) offlineCluster(test *testing.T, ns *namespace) (*testutils.OfflineCluster, error) {
+	if t.offline != nil {
+		return t.offline, nil
 	}
+
+	offline := testutils.NewOfflineCluster()
+	if t.OfflineCluster != nil {
+		var err error
+		if offline, err = t.OfflineCluster(); err != nil {
+			return nil, err
+		}
+	}
+
+	// in offline mode, the in-memory cluster is the cluster of the test case, which already has the namespace
+	if !t.Offline {
+		cl, err := offline.Client(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.CreateNamespace(test, cl, ns); err != nil && !k8serrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create test namespace: %w", err)
+		}
+	}
+
+	t.offline = offline
+	return t.offline, nil
 }
 
 // Derive the namespace to use for the test case from its name
@@ -450,14 +598,22 @@ func (t *Case) determineNamespace() (*namespace, error) {
 // CollectTestStepFiles collects a map of test steps and their associated files
 // from a directory.
 func (t *Case) CollectTestStepFiles() (map[int64][]string, error) {
+	return t.collectTestStepFiles(t.Dir)
+}
+
+func (t *Case) collectTestStepFiles(dir string) (map[int64][]string, error) {
 	testStepFiles := map[int64][]string{}
 
-	files, err := os.ReadDir(t.Dir)
+	files, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, file := range files {
+		if file.Name() == v1beta1.MatrixFile {
+			continue
+		}
+
 		index, err := getIndexFromFile(file.Name())
 		if err != nil {
 			return nil, err
@@ -471,7 +627,7 @@ func (t *Case) CollectTestStepFiles() (map[int64][]string, error) {
 			testStepFiles[index] = []string{}
 		}
 
-		testStepPath := filepath.Join(t.Dir, file.Name())
+		testStepPath := filepath.Join(dir, file.Name())
 
 		if file.IsDir() {
 			testStepDir, err := os.ReadDir(testStepPath)
@@ -506,11 +662,50 @@ func getIndexFromFile(fileName string) (int64, error) {
 
 // LoadTestSteps loads all of the test steps for a test case.
 func (t *Case) LoadTestSteps() error {
-	testStepFiles, err := t.CollectTestStepFiles()
+	testSteps, err := t.loadTestSteps(t.Dir)
 	if err != nil {
 		return err
 	}
 
+	t.Steps = testSteps
+	return nil
+}
+
+// testInfo returns the metadata of the test case which is exported to its commands. Relative directories are made
+// absolute, as the commands do not run in the current working directory.
+func (t *Case) testInfo() *testutils.TestInfo {
+	artifactsDir := t.ArtifactsDir
+	if artifactsDir == "" {
+		artifactsDir = "."
+	}
+	return &testutils.TestInfo{
+		Suite:        t.Suite,
+		SuiteDir:     absPath(t.SuiteDir),
+		Test:         t.Name,
+		TestDir:      absPath(t.Dir),
+		ArtifactsDir: absPath(artifactsDir),
+		RunLabels:    t.RunLabels,
+	}
+}
+
+// absPath returns the absolute representation of path, or path itself if it is empty or can not be made absolute.
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// loadTestSteps loads the test steps in dir with the settings of the test case, sorted by index.
+func (t *Case) loadTestSteps(dir string) ([]*Step, error) {
+	testStepFiles, err := t.collectTestStepFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	testSteps := []*Step{}
 
 	for index, files := range testStepFiles {
@@ -518,16 +713,20 @@ func (t *Case) LoadTestSteps() error {
 			Timeout:       t.Timeout,
 			Index:         int(index),
 			SkipDelete:    t.SkipDelete,
-			Dir:           t.Dir,
+			Dir:           dir,
 			TestRunLabels: t.RunLabels,
 			Asserts:       []client.Object{},
 			Apply:         []client.Object{},
 			Errors:        []client.Object{},
+
+			SemanticComparison: t.SemanticComparison,
+			ApplyOptions:       t.ApplyOptions,
+			ExpandVariables:    t.ExpandVariables,
 		}
 
 		for _, file := range files {
 			if err := testStep.LoadYAML(file); err != nil {
-				return err
+				return nil, err
 			}
 		}
 
@@ -538,8 +737,7 @@ func (t *Case) LoadTestSteps() error {
 		return testSteps[i].Index < testSteps[j].Index
 	})
 
-	t.Steps = testSteps
-	return nil
+	return testSteps, nil
 }
 
 func newClient(kubeconfig, context string) func(bool) (client.Client, error) {
@@ -565,3 +763,14 @@ func newDiscoveryClient(kubeconfig, context string) func() (discovery.DiscoveryI
 		return discovery.NewDiscoveryClientForConfig(config)
 	}
 }
+
+func newKubernetesClient(kubeconfig, context string) func() (kubernetes.Interface, error) {
+	return func() (kubernetes.Interface, error) {
+		config, err := k8s.BuildConfigWithContext(kubeconfig, context)
+		if err != nil {
+			return nil, err
+		}
+
+		return kubernetes.NewForConfig(config)
+	}
+}
@@ -1,20 +1,65 @@
 package test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/stackabletech/kuttl/pkg/report"
 	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
 )
 
+// failingTestResultEnv is set to the file runFailing writes the result of its function to in the child process.
+const failingTestResultEnv = "KUTTL_FAILING_TEST_RESULT"
+
+// runFailing runs fn, which is expected to fail t, and unmarshals the JSON of the value returned by fn into result.
+// As the failure can not be undone, fn is run by the same test in a child process of the test binary, where t fails
+// and is ended after fn has returned. t must not be parallel and fn must not depend on the state of the parent
+// process.
+func runFailing(t *testing.T, fn func(t *testing.T) interface{}, result interface{}) {
+	if path := os.Getenv(failingTestResultEnv); path != "" {
+		data, err := json.Marshal(fn(t))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0600))
+		t.SkipNow()
+	}
+
+	// the pattern matches t and none of its siblings, level by level
+	levels := strings.Split(t.Name(), "/")
+	for i := range levels {
+		levels[i] = "^" + regexp.QuoteMeta(levels[i]) + "$"
+	}
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	//nolint:gosec // runs the test binary itself
+	cmd := exec.Command(os.Args[0], "-test.run="+strings.Join(levels, "/"), "-test.v")
+	cmd.Env = append(os.Environ(), failingTestResultEnv+"="+path)
+	output, err := cmd.CombinedOutput()
+	require.Error(t, err, "expected the test to fail:\n%s", output)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err, "the test did not return a result:\n%s", output)
+	require.NoError(t, json.Unmarshal(data, result))
+}
+
 // Verify the test state as loaded from disk.
 // Each test provides a path to a set of test steps and their rendered result.
 func TestLoadTestSteps(t *testing.T) {
@@ -342,6 +387,8 @@ func TestLoadTestSteps(t *testing.T) {
 			assert.Equal(t, len(tt.testSteps), len(testStepsVal))
 			for index := range tt.testSteps {
 				tt.testSteps[index].Dir = tt.path
+				// the files are only kept to be loaded again with the variables expanded
+				tt.testSteps[index].files = testStepsVal[index].files
 				assert.Equal(t, tt.testSteps[index].Apply, testStepsVal[index].Apply, "apply objects need to match")
 				assert.Equal(t, tt.testSteps[index].Asserts, testStepsVal[index].Asserts, "assert objects need to match")
 				assert.Equal(t, tt.testSteps[index].Errors, testStepsVal[index].Errors, "error objects need to match")
@@ -429,3 +476,300 @@ func TestGetIndexFromFile(t *testing.T) {
 func TestDetermineNamespace(t *testing.T) {
 	assert.Equal(t, "kuttl-c7e64f7a24", deriveNamespaceFromTestcaseName("smoke_airflow-2.9.2_openshift-false_executor-kubernetes"))
 }
+
+// Unit test steps share an in-memory cluster, other steps run against the cluster of the test case.
+func TestCaseUnitTestSteps(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	c := Case{
+		Name:       "unit-test",
+		Logger:     testutils.NewTestLogger(t, ""),
+		SkipDelete: true,
+		Suppress:   []string{"events"},
+		Steps: []*Step{
+			{
+				Name:    "create-in-memory",
+				Index:   0,
+				Step:    &harness.TestStep{UnitTest: true},
+				Apply:   []client.Object{testutils.NewPod("hello", "")},
+				Asserts: []client.Object{testutils.NewPod("hello", "")},
+				Timeout: 1,
+			},
+			{
+				Name:    "assert-in-memory",
+				Index:   1,
+				Step:    &harness.TestStep{UnitTest: true},
+				Asserts: []client.Object{testutils.NewPod("hello", "")},
+				Timeout: 1,
+			},
+			{
+				Name:    "assert-not-in-cluster",
+				Index:   2,
+				Errors:  []client.Object{testutils.NewPod("hello", "")},
+				Timeout: 1,
+			},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	c.Run(t, &report.Testsuite{})
+
+	// the test namespace is created in the cluster and in the in-memory cluster
+	ns := deriveNamespaceFromTestcaseName("unit-test")
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: ns}, &corev1.Namespace{}))
+	offline, err := c.offline.Client(false)
+	require.NoError(t, err)
+	assert.NoError(t, offline.Get(context.TODO(), client.ObjectKey{Name: ns}, &corev1.Namespace{}))
+	assert.NoError(t, offline.Get(context.TODO(), client.ObjectKey{Namespace: ns, Name: "hello"}, &corev1.Pod{}))
+}
+
+func TestCaseFinallySteps(t *testing.T) {
+	t.Run("steps succeed", func(t *testing.T) {
+		cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		c := Case{
+			Name:       "finally",
+			Logger:     testutils.NewTestLogger(t, ""),
+			SkipDelete: true,
+			Suppress:   []string{"events"},
+			Steps: []*Step{
+				{
+					Name:    "cleanup",
+					Index:   0,
+					Step:    &harness.TestStep{Finally: true},
+					Apply:   []client.Object{testutils.NewPod("cleanup", "")},
+					Timeout: 1,
+				},
+				{
+					Name:    "create",
+					Index:   1,
+					Apply:   []client.Object{testutils.NewPod("create", "")},
+					Timeout: 1,
+				},
+			},
+			Client:          func(bool) (client.Client, error) { return cl, nil },
+			DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		}
+
+		ts := &report.Testsuite{}
+		c.Run(t, ts)
+
+		names := []string{}
+		for _, tc := range ts.Testcases {
+			names = append(names, tc.Name)
+		}
+		assert.Equal(t, []string{"setup", "step 1-create", "finally step 0-cleanup"}, names)
+
+		ns := deriveNamespaceFromTestcaseName("finally")
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: ns, Name: "cleanup"}, &corev1.Pod{}))
+	})
+
+	t.Run("step fails", func(t *testing.T) {
+		type result struct {
+			Report  *report.Testsuite
+			Created []string
+		}
+
+		res := &result{}
+		runFailing(t, func(t *testing.T) interface{} {
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+			c := Case{
+				Name:       "finally",
+				Logger:     testutils.NewTestLogger(t, ""),
+				SkipDelete: true,
+				Suppress:   []string{"events"},
+				Steps: []*Step{
+					{
+						Name:    "create",
+						Index:   0,
+						Apply:   []client.Object{testutils.NewPod("create", "")},
+						Timeout: 1,
+					},
+					{
+						Name:    "check",
+						Index:   1,
+						Asserts: []client.Object{testutils.NewPod("missing", "")},
+						Timeout: 1,
+					},
+					{
+						Name:    "skipped",
+						Index:   2,
+						Apply:   []client.Object{testutils.NewPod("skipped", "")},
+						Timeout: 1,
+					},
+					{
+						Name:    "cleanup",
+						Index:   3,
+						Step:    &harness.TestStep{Finally: true},
+						Apply:   []client.Object{testutils.NewPod("cleanup", "")},
+						Timeout: 1,
+					},
+					{
+						Name:    "broken-cleanup",
+						Index:   4,
+						Step:    &harness.TestStep{Finally: true},
+						Asserts: []client.Object{testutils.NewPod("missing", "")},
+						Timeout: 1,
+					},
+				},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+
+			ts := &report.Testsuite{}
+			c.Run(t, ts)
+
+			pods := &corev1.PodList{}
+			require.NoError(t, cl.List(context.TODO(), pods))
+			created := []string{}
+			for _, pod := range pods.Items {
+				created = append(created, pod.Name)
+			}
+			return result{Report: ts, Created: created}
+		}, res)
+
+		// the finally steps run after the failed step, but the following regular steps do not
+		assert.ElementsMatch(t, []string{"create", "cleanup"}, res.Created)
+
+		failures := map[string]string{}
+		names := []string{}
+		for _, tc := range res.Report.Testcases {
+			names = append(names, tc.Name)
+			if tc.Failure != nil {
+				failures[tc.Name] = tc.Failure.Message
+			}
+		}
+		assert.Equal(t, []string{"setup", "step 0-create", "step 1-check", "finally step 3-cleanup", "finally step 4-broken-cleanup"}, names)
+
+		// the failure of the finally step is reported separately from the original failure
+		assert.Equal(t, map[string]string{
+			"step 1-check":                  "failed in step 1-check",
+			"finally step 4-broken-cleanup": "failed in finally step 4-broken-cleanup",
+		}, failures)
+	})
+}
+
+func TestCaseFailureReport(t *testing.T) {
+	failure := &report.Failure{}
+	runFailing(t, func(t *testing.T) interface{} {
+		cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		c := Case{
+			Name:       "report",
+			Logger:     testutils.NewTestLogger(t, ""),
+			SkipDelete: true,
+			Suppress:   []string{"events"},
+			Steps: []*Step{
+				{
+					Name:    "create",
+					Index:   0,
+					Apply:   []client.Object{testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "zk"})},
+					Timeout: 1,
+				},
+				{
+					Name:  "check",
+					Index: 1,
+					Asserts: []client.Object{
+						testutils.WithSpec(t, testutils.WithLabels(t, testutils.NewPod("hello", ""), map[string]string{"app": "hdfs"}),
+							map[string]interface{}{"serviceAccountName": "hdfs"}),
+						testutils.NewPod("missing", ""),
+					},
+					Timeout: 1,
+				},
+			},
+			Client:          func(bool) (client.Client, error) { return cl, nil },
+			DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		}
+
+		ts := &report.Testsuite{}
+		c.Run(t, ts)
+		for _, tc := range ts.Testcases {
+			if tc.Failure != nil {
+				return tc.Failure
+			}
+		}
+		return nil
+	}, failure)
+
+	// the failure lists every mismatch of every asserted object, but not their diffs
+	ns := deriveNamespaceFromTestcaseName("report")
+	assert.Equal(t, "failed in step 1-check", failure.Message)
+	assert.Equal(t, "resource Pod:"+ns+"/hello: 2 mismatches:\n"+
+		"  .metadata.labels.app: value mismatch, expected: hdfs != actual: zk\n"+
+		"  .spec.serviceAccountName: key is missing from map\n"+
+		`pods "missing" not found`, failure.Text)
+}
+
+func TestCaseVariables(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	c := Case{
+		Name:       "variables",
+		Logger:     testutils.NewTestLogger(t, ""),
+		SkipDelete: true,
+		Suppress:   []string{"events"},
+		Variables:  map[string]string{"SUITE": "suite"},
+		Steps: []*Step{
+			{
+				Name:    "capture",
+				Index:   0,
+				Step:    &harness.TestStep{Commands: []harness.Command{{Script: "echo $SUITE-case", Output: &harness.CommandOutput{Name: "CASE"}}}},
+				Timeout: 1,
+			},
+			{
+				Name:    "use",
+				Index:   1,
+				Step:    &harness.TestStep{Commands: []harness.Command{{Script: `test "$CASE" = suite-case`}}},
+				Timeout: 1,
+			},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	c.Run(t, &report.Testsuite{})
+
+	// the variables of the test suite are not changed by the test case
+	assert.Equal(t, map[string]string{"SUITE": "suite"}, c.Variables)
+	assert.Equal(t, map[string]string{"SUITE": "suite", "CASE": "suite-case"}, c.variables)
+}
+
+func TestCaseTestInfo(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	dir := t.TempDir()
+
+	script := `test "$KUTTL_SUITE" = e2e && test "$KUTTL_SUITE_DIR" = "` + filepath.Dir(dir) + `" && test "$KUTTL_TEST" = metadata && test "$KUTTL_TEST_DIR" = "` + dir + `" && ` +
+		`test "$KUTTL_STEP_INDEX" = 1 && test "$KUTTL_STEP_NAME" = check && test "$KUTTL_RUN_LABELS" = flavor=a && ` +
+		`test "$KUTTL_ARTIFACTS_DIR" = "` + dir + `" && test -n "$KUTTL_VERSION"`
+
+	c := Case{
+		Name:         "metadata",
+		Dir:          dir,
+		Suite:        "e2e",
+		SuiteDir:     filepath.Dir(dir),
+		ArtifactsDir: dir,
+		RunLabels:    labels.Set{"flavor": "a"},
+		Logger:       testutils.NewTestLogger(t, ""),
+		SkipDelete:   true,
+		Suppress:     []string{"events"},
+		Steps: []*Step{
+			{
+				Name:    "check",
+				Index:   1,
+				Step:    &harness.TestStep{Commands: []harness.Command{{Script: script}}},
+				Timeout: 1,
+			},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	ts := &report.Testsuite{}
+	c.Run(t, ts)
+
+	for _, tc := range ts.Testcases {
+		assert.Nil(t, tc.Failure, tc.Name)
+	}
+}
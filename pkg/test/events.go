@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	eventsbeta1 "k8s.io/api/events/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// event is the API version independent representation of a Kubernetes event used by event assertions.
+type event struct {
+	regarding corev1.ObjectReference
+	reason    string
+	eventType string
+	note      string
+	count     int
+	timestamp time.Time
+}
+
+// listEvents lists the events of namespace, trying the events.k8s.io/v1, events.k8s.io/v1beta1 and core/v1 APIs
+// in turn like Case.CollectEvents. The next API is only tried if the cluster does not serve the previous one, other
+// errors, e.g. missing permissions, are returned. The events are sorted by timestamp.
+func listEvents(cl client.Client, namespace string) ([]event, error) {
+	events, err := listEventsV1(cl, namespace)
+	if isAPIMissing(err) {
+		events, err = listEventsBeta1(cl, namespace)
+	}
+	if isAPIMissing(err) {
+		events, err = listEventsCoreV1(cl, namespace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events in namespace %s: %w", namespace, err)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].timestamp.Before(events[j].timestamp) })
+	return events, nil
+}
+
+// isAPIMissing returns true if err means that the cluster does not serve the listed API.
+func isAPIMissing(err error) bool {
+	return k8serrors.IsNotFound(err) || meta.IsNoMatchError(err)
+}
+
+func listEventsV1(cl client.Client, namespace string) ([]event, error) {
+	eventsList := &eventsv1.EventList{}
+	if err := cl.List(context.TODO(), eventsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	events := make([]event, 0, len(eventsList.Items))
+	for _, e := range eventsList.Items {
+		count := int(e.DeprecatedCount)
+		if e.Series != nil {
+			count = int(e.Series.Count)
+		}
+		events = append(events, event{
+			regarding: e.Regarding, reason: e.Reason, eventType: e.Type, note: e.Note, count: count,
+			timestamp: eventTimestamp(e.EventTime.Time, e.DeprecatedFirstTimestamp.Time, e.CreationTimestamp.Time),
+		})
+	}
+	return events, nil
+}
+
+func listEventsBeta1(cl client.Client, namespace string) ([]event, error) {
+	eventsList := &eventsbeta1.EventList{}
+	if err := cl.List(context.TODO(), eventsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	events := make([]event, 0, len(eventsList.Items))
+	for _, e := range eventsList.Items {
+		count := int(e.DeprecatedCount)
+		if e.Series != nil {
+			count = int(e.Series.Count)
+		}
+		events = append(events, event{
+			regarding: e.Regarding, reason: e.Reason, eventType: e.Type, note: e.Note, count: count,
+			timestamp: eventTimestamp(e.EventTime.Time, e.DeprecatedFirstTimestamp.Time, e.CreationTimestamp.Time),
+		})
+	}
+	return events, nil
+}
+
+func listEventsCoreV1(cl client.Client, namespace string) ([]event, error) {
+	eventsList := &corev1.EventList{}
+	if err := cl.List(context.TODO(), eventsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	events := make([]event, 0, len(eventsList.Items))
+	for _, e := range eventsList.Items {
+		events = append(events, event{
+			regarding: e.InvolvedObject, reason: e.Reason, eventType: e.Type, note: e.Message, count: int(e.Count),
+			timestamp: eventTimestamp(e.EventTime.Time, e.FirstTimestamp.Time, e.CreationTimestamp.Time),
+		})
+	}
+	return events, nil
+}
+
+// eventTimestamp returns the first non-zero of the given timestamps.
+func eventTimestamp(timestamps ...time.Time) time.Time {
+	for _, t := range timestamps {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matches returns true if e satisfies all conditions of the event assertion, whose compiled note regex is note.
+func (e *event) matches(eventAssert *harness.TestEventAssert, note *regexp.Regexp) bool {
+	regarding := eventAssert.Regarding
+	switch {
+	case regarding.APIVersion != "" && regarding.APIVersion != e.regarding.APIVersion,
+		regarding.Kind != "" && regarding.Kind != e.regarding.Kind,
+		regarding.Name != "" && regarding.Name != e.regarding.Name,
+		eventAssert.Reason != "" && eventAssert.Reason != e.reason,
+		eventAssert.Type != "" && eventAssert.Type != e.eventType:
+		return false
+	}
+	return note == nil || note.MatchString(e.note)
+}
+
+// CheckAssertEvents checks the event assertions of the step's TestAssert, returning an error for every failed assertion.
+func (s *Step) CheckAssertEvents(namespace string) []error {
+	if s.Assert == nil || len(s.Assert.Events) == 0 {
+		return nil
+	}
+	// the note regexes are compiled when the TestAssert is loaded, but not if it was set otherwise
+	if len(s.eventNotes) != len(s.Assert.Events) {
+		notes := make([]*regexp.Regexp, len(s.Assert.Events))
+		for i := range s.Assert.Events {
+			note, err := s.Assert.Events[i].Compile()
+			if err != nil {
+				return []error{fmt.Errorf("invalid event assertion %d: %w", i, err)}
+			}
+			notes[i] = note
+		}
+		s.eventNotes = notes
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	eventsByNamespace := map[string][]event{}
+	testErrors := []error{}
+
+	// with OrderedEvents, every assertion must be matched by an event emitted after the one matching the previous assertion
+	var previous time.Time
+
+	for i := range s.Assert.Events {
+		eventAssert := &s.Assert.Events[i]
+
+		eventsNs := namespace
+		if eventAssert.Namespace != "" {
+			eventsNs = eventAssert.Namespace
+		}
+		events, ok := eventsByNamespace[eventsNs]
+		if !ok {
+			if events, err = listEvents(cl, eventsNs); err != nil {
+				return append(testErrors, err)
+			}
+			eventsByNamespace[eventsNs] = events
+		}
+
+		count := 0
+		var first *event
+		for j := range events {
+			if !events[j].matches(eventAssert, s.eventNotes[i]) {
+				continue
+			}
+			if first == nil && (!s.Assert.OrderedEvents || !events[j].timestamp.Before(previous)) {
+				first = &events[j]
+			}
+			if events[j].count > 1 {
+				count += events[j].count
+			} else {
+				count++
+			}
+		}
+
+		switch {
+		case count < eventAssert.GetMinCount():
+			testErrors = append(testErrors, fmt.Errorf("event assertion %s: found %d matching events, expected at least %d", eventAssert.String(), count, eventAssert.GetMinCount()))
+		case s.Assert.OrderedEvents && first == nil:
+			testErrors = append(testErrors, fmt.Errorf("event assertion %s: no matching event was emitted after the event matching the previous assertion", eventAssert.String()))
+		case s.Assert.OrderedEvents:
+			previous = first.timestamp
+		}
+	}
+
+	return testErrors
+}
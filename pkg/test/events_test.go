@@ -0,0 +1,182 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	eventsbeta1 "k8s.io/api/events/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+func newTestEvent(name string, at time.Time, reason, eventType, note string, count int32) *eventsv1.Event {
+	e := &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		EventTime:  metav1.NewMicroTime(at),
+		Regarding:  corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "hello", Namespace: testNamespace},
+		Reason:     reason,
+		Type:       eventType,
+		Note:       note,
+	}
+	if count > 1 {
+		e.Series = &eventsv1.EventSeries{Count: count, LastObservedTime: metav1.NewMicroTime(at)}
+	}
+	return e
+}
+
+func TestCheckAssertEvents(t *testing.T) {
+	now := time.Now()
+	events := []client.Object{
+		newTestEvent("scheduled", now, "Scheduled", "Normal", "Successfully assigned world/hello to node-1", 0),
+		newTestEvent("started", now.Add(time.Second), "Started", "Normal", "Started container main", 0),
+		newTestEvent("backoff", now.Add(2*time.Second), "BackOff", "Warning", "Back-off restarting failed container", 3),
+	}
+
+	pod := harness.EventObjectReference{APIVersion: "v1", Kind: "Pod", Name: "hello"}
+
+	for _, tt := range []struct {
+		name    string
+		events  []harness.TestEventAssert
+		ordered bool
+		errs    []string
+	}{
+		{
+			name:   "reason and type",
+			events: []harness.TestEventAssert{{Regarding: pod, Reason: "Scheduled", Type: "Normal"}},
+		},
+		{
+			name:   "note regex",
+			events: []harness.TestEventAssert{{Note: "assigned .*/hello to"}},
+		},
+		{
+			name:   "no matching event",
+			events: []harness.TestEventAssert{{Reason: "Killing"}},
+			errs:   []string{"event assertion [reason==Killing]: found 0 matching events, expected at least 1"},
+		},
+		{
+			name:   "other object",
+			events: []harness.TestEventAssert{{Regarding: harness.EventObjectReference{Kind: "Pod", Name: "other"}, Reason: "Scheduled"}},
+			errs:   []string{"event assertion [reason==Scheduled,regarding==/Pod other]: found 0 matching events, expected at least 1"},
+		},
+		{
+			name:   "series count",
+			events: []harness.TestEventAssert{{Reason: "BackOff", MinCount: 3}},
+		},
+		{
+			name:   "series count too low",
+			events: []harness.TestEventAssert{{Type: "Warning", MinCount: 4}},
+			errs:   []string{"event assertion [type==Warning]: found 3 matching events, expected at least 4"},
+		},
+		{
+			name:    "ordered",
+			events:  []harness.TestEventAssert{{Reason: "Scheduled"}, {Reason: "Started"}, {Reason: "BackOff"}},
+			ordered: true,
+		},
+		{
+			name:    "out of order",
+			events:  []harness.TestEventAssert{{Reason: "Started"}, {Reason: "Scheduled"}},
+			ordered: true,
+			errs:    []string{"event assertion [reason==Scheduled]: no matching event was emitted after the event matching the previous assertion"},
+		},
+		{
+			name:   "out of order without ordering",
+			events: []harness.TestEventAssert{{Reason: "Started"}, {Reason: "Scheduled"}},
+		},
+		{
+			name:   "invalid note regex",
+			events: []harness.TestEventAssert{{Reason: "Started"}, {Note: "("}},
+			errs:   []string{"invalid event assertion 1: event assertion has invalid note regex \"(\": error parsing regexp: missing closing ): `(`"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			step := Step{
+				Assert: &harness.TestAssert{Events: tt.events, OrderedEvents: tt.ordered},
+				Client: func(bool) (client.Client, error) {
+					return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(events...).Build(), nil
+				},
+				Logger: testutils.NewTestLogger(t, ""),
+			}
+
+			errs := step.CheckAssertEvents(testNamespace)
+			assert.Len(t, errs, len(tt.errs))
+			for i := range errs {
+				if i < len(tt.errs) {
+					assert.EqualError(t, errs[i], tt.errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestListEventsFallback(t *testing.T) {
+	coreEvent := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "scheduled", Namespace: testNamespace},
+		InvolvedObject: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "hello", Namespace: testNamespace},
+		Reason:         "Scheduled",
+	}
+
+	for _, tt := range []struct {
+		name    string
+		listErr error
+		reasons []string
+		err     string
+	}{
+		{
+			name:    "API not served",
+			listErr: &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "events.k8s.io", Kind: "Event"}},
+			reasons: []string{"Scheduled"},
+		},
+		{
+			name:    "API not found",
+			listErr: k8serrors.NewNotFound(schema.GroupResource{Group: "events.k8s.io", Resource: "events"}, ""),
+			reasons: []string{"Scheduled"},
+		},
+		{
+			// other errors must not be hidden by falling back to an API which may list no events
+			name:    "forbidden",
+			listErr: k8serrors.NewForbidden(schema.GroupResource{Group: "events.k8s.io", Resource: "events"}, "", errors.New("RBAC")),
+			err:     `failed to list events in namespace world: events.events.k8s.io is forbidden: RBAC`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(coreEvent).WithInterceptorFuncs(interceptor.Funcs{
+				List: func(ctx context.Context, cl client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					switch list.(type) {
+					case *eventsv1.EventList, *eventsbeta1.EventList:
+						return tt.listErr
+					}
+					return cl.List(ctx, list, opts...)
+				},
+			}).Build()
+
+			events, err := listEvents(cl, testNamespace)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			reasons := []string{}
+			for _, e := range events {
+				reasons = append(reasons, e.reason)
+			}
+			assert.Equal(t, tt.reasons, reasons)
+		})
+	}
+}
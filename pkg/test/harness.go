@@ -18,9 +18,11 @@ import (
 	volumetypes "github.com/docker/docker/api/types/volume"
 	docker "github.com/docker/docker/client"
 	"gopkg.in/yaml.v2"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -46,6 +48,10 @@ type Harness struct {
 	docker        testutils.DockerClient
 	client        client.Client
 	dclient       discovery.DiscoveryInterface
+	kclient       kubernetes.Interface
+	watchCache    *testutils.WatchCache
+	offline       *testutils.OfflineCluster
+	crds          []*apiextv1.CustomResourceDefinition
 	env           *envtest.Environment
 	kind          *kind
 	tempPath      string
@@ -54,6 +60,9 @@ type Harness struct {
 	stopping      bool
 	bgProcesses   []*exec.Cmd
 	report        *report.Testsuites
+	hookReport    *report.Testsuite
+	afterAll      *harness.LifecycleHook
+	variables     map[string]string
 	RunLabels     labels.Set
 }
 
@@ -74,26 +83,73 @@ func (h *Harness) LoadTests(dir string) ([]*Case, error) {
 	timeout := h.GetTimeout()
 	h.T.Logf("going to run test suite with timeout of %d seconds for each step", timeout)
 
+	applyOptions, err := h.applyOptions()
+	if err != nil {
+		return nil, err
+	}
+
 	for _, file := range files {
 		if !file.IsDir() {
 			continue
 		}
 
-		tests = append(tests, &Case{
+		test := &Case{
 			Timeout:            timeout,
 			Steps:              []*Step{},
 			Name:               file.Name(),
 			PreferredNamespace: h.TestSuite.Namespace,
 			Dir:                filepath.Join(dir, file.Name()),
+			Suite:              h.TestSuite.Name,
+			SuiteDir:           dir,
+			ArtifactsDir:       h.TestSuite.ArtifactsDir,
 			SkipDelete:         h.TestSuite.SkipDelete,
 			Suppress:           h.TestSuite.Suppress,
 			RunLabels:          h.RunLabels,
-		})
+			SemanticComparison: h.TestSuite.SemanticComparison,
+			ApplyOptions:       applyOptions,
+			Offline:            h.TestSuite.Offline,
+			BeforeEach:         h.TestSuite.BeforeEach,
+			AfterEach:          h.TestSuite.AfterEach,
+			ExpandVariables:    h.TestSuite.ExpandVariables,
+			Variables:          h.variables,
+		}
+
+		// a test case with a matrix is run once for every combination of its variables
+		matrix, err := loadMatrix(test.Dir)
+		if err != nil {
+			return nil, err
+		}
+		if matrix == nil {
+			tests = append(tests, test)
+			continue
+		}
+		// the combinations run in parallel, so they can not share a namespace
+		if h.TestSuite.Namespace != "" {
+			return nil, fmt.Errorf("test %s has a matrix, which can not be run in the namespace %s of the test suite", test.Name, h.TestSuite.Namespace)
+		}
+		matrixTests, err := expandMatrix(test, matrix)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, matrixTests...)
 	}
 
 	return tests, nil
 }
 
+// applyOptions returns the validated apply options of the test suite.
+func (h *Harness) applyOptions() (testutils.ApplyOptions, error) {
+	applyOptions := testutils.ApplyOptions{
+		Strategy:       h.TestSuite.ApplyStrategy,
+		FieldManager:   h.TestSuite.FieldManager,
+		ForceConflicts: h.TestSuite.ForceConflicts,
+	}
+	if err := applyOptions.Validate(); err != nil {
+		return testutils.ApplyOptions{}, fmt.Errorf("invalid apply options in test suite: %w", err)
+	}
+	return applyOptions, nil
+}
+
 // GetLogger returns an initialized test logger.
 func (h *Harness) GetLogger() testutils.Logger {
 	if h.logger == nil {
@@ -315,6 +371,15 @@ func (h *Harness) Client(forceNew bool) (client.Client, error) {
 		return h.client, nil
 	}
 
+	if h.TestSuite.Offline {
+		offline, err := h.offlineCluster()
+		if err != nil {
+			return nil, err
+		}
+		h.client, err = offline.Client(forceNew)
+		return h.client, err
+	}
+
 	cfg, err := h.Config()
 	if err != nil {
 		return nil, err
@@ -335,6 +400,15 @@ func (h *Harness) DiscoveryClient() (discovery.DiscoveryInterface, error) {
 		return h.dclient, nil
 	}
 
+	if h.TestSuite.Offline {
+		offline, err := h.offlineCluster()
+		if err != nil {
+			return nil, err
+		}
+		h.dclient, err = offline.DiscoveryClient()
+		return h.dclient, err
+	}
+
 	cfg, err := h.Config()
 	if err != nil {
 		return nil, err
@@ -344,6 +418,99 @@ func (h *Harness) DiscoveryClient() (discovery.DiscoveryInterface, error) {
 	return h.dclient, err
 }
 
+// WatchCache returns the watch cache shared by all tests of the harness, or nil if the test suite does not use one.
+func (h *Harness) WatchCache() (*testutils.WatchCache, error) {
+	h.clientLock.Lock()
+	defer h.clientLock.Unlock()
+
+	if h.watchCache != nil || !h.TestSuite.WatchCache || h.TestSuite.Offline {
+		return h.watchCache, nil
+	}
+
+	cfg, err := h.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	h.watchCache, err = testutils.NewWatchCache(cfg)
+	return h.watchCache, err
+}
+
+// KubernetesClient returns the current Kubernetes clientset for the test harness.
+func (h *Harness) KubernetesClient() (kubernetes.Interface, error) {
+	h.clientLock.Lock()
+	defer h.clientLock.Unlock()
+
+	if h.kclient != nil {
+		return h.kclient, nil
+	}
+
+	if h.TestSuite.Offline {
+		offline, err := h.offlineCluster()
+		if err != nil {
+			return nil, err
+		}
+		h.kclient, err = offline.KubernetesClient()
+		return h.kclient, err
+	}
+
+	cfg, err := h.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	h.kclient, err = kubernetes.NewForConfig(cfg)
+	return h.kclient, err
+}
+
+// OfflineCluster returns the in-memory cluster to run the unit test steps of a test case against. In offline mode,
+// this is the cluster shared by all tests, otherwise every call returns a new, empty cluster.
+func (h *Harness) OfflineCluster() (*testutils.OfflineCluster, error) {
+	h.clientLock.Lock()
+	defer h.clientLock.Unlock()
+
+	if h.TestSuite.Offline {
+		return h.offlineCluster()
+	}
+
+	crds, err := h.offlineCRDs()
+	if err != nil {
+		return nil, err
+	}
+	return testutils.NewOfflineCluster(crds...), nil
+}
+
+// offlineCluster returns the in-memory cluster used instead of a Kubernetes cluster in offline mode.
+// The caller must hold clientLock.
+func (h *Harness) offlineCluster() (*testutils.OfflineCluster, error) {
+	if h.offline != nil {
+		return h.offline, nil
+	}
+
+	crds, err := h.offlineCRDs()
+	if err != nil {
+		return nil, err
+	}
+	h.T.Logf("running tests offline, against an in-memory cluster serving %d crds.", len(crds))
+	h.offline = testutils.NewOfflineCluster(crds...)
+	return h.offline, nil
+}
+
+// offlineCRDs returns the CRDs of the test suite, which in-memory clusters serve in addition to the built-in types.
+// The caller must hold clientLock.
+func (h *Harness) offlineCRDs() ([]*apiextv1.CustomResourceDefinition, error) {
+	if h.crds != nil {
+		return h.crds, nil
+	}
+
+	crds, err := testutils.LoadCRDs(h.TestSuite.CRDDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load crds: %w", err)
+	}
+	h.crds = crds
+	return h.crds, nil
+}
+
 // DockerClient returns the Docker client to use for the test harness.
 func (h *Harness) DockerClient() (testutils.DockerClient, error) {
 	if h.docker != nil {
@@ -377,6 +544,11 @@ func (h *Harness) RunTests() {
 		realTestSuite[testDir] = tempTests
 	}
 
+	watchCache, err := h.WatchCache()
+	if err != nil {
+		h.T.Logf("watch cache unavailable, falling back to polling: %v", err)
+	}
+
 	h.T.Run("harness", func(t *testing.T) {
 		for testDir, tests := range realTestSuite {
 			suiteReport := h.report.NewSuite(testDir)
@@ -385,6 +557,9 @@ func (h *Harness) RunTests() {
 
 				test.Client = h.Client
 				test.DiscoveryClient = h.DiscoveryClient
+				test.KubernetesClient = h.KubernetesClient
+				test.OfflineCluster = h.OfflineCluster
+				test.WatchCache = watchCache
 
 				t.Run(test.Name, func(t *testing.T) {
 					// testing.T.Parallel may block, so run it before we read time for our
@@ -461,6 +636,7 @@ func (h *Harness) Run() {
 func (h *Harness) Setup() {
 	rand.Seed(time.Now().UTC().UnixNano())
 	h.report = report.NewSuiteCollection(h.TestSuite.Name)
+	h.variables = map[string]string{}
 	h.T.Log("starting setup")
 
 	cl, err := h.Client(false)
@@ -473,27 +649,29 @@ func (h *Harness) Setup() {
 		h.fatal(fmt.Errorf("fatal error getting discovery client: %v", err))
 	}
 
-	// Install CRDs
-	crdKinds := []runtime.Object{
-		testutils.NewResource("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", ""),
-		testutils.NewResource("apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "", ""),
-	}
-	crds, err := testutils.InstallManifests(context.TODO(), cl, dClient, h.TestSuite.CRDDir, crdKinds...)
-	if err != nil {
-		h.fatal(fmt.Errorf("fatal error installing crds: %v", err))
-	}
+	// Install CRDs, unless offline: the in-memory cluster serves the CRDs without installing them.
+	if !h.TestSuite.Offline {
+		crdKinds := []runtime.Object{
+			testutils.NewResource("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", ""),
+			testutils.NewResource("apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "", ""),
+		}
+		crds, err := testutils.InstallManifests(context.TODO(), cl, dClient, h.TestSuite.CRDDir, crdKinds...)
+		if err != nil {
+			h.fatal(fmt.Errorf("fatal error installing crds: %v", err))
+		}
 
-	if err := envtest.WaitForCRDs(h.config, crds, envtest.CRDInstallOptions{
-		PollInterval: 100 * time.Millisecond,
-		MaxTime:      10 * time.Second,
-	}); err != nil {
-		h.fatal(fmt.Errorf("fatal error waiting for crds: %v", err))
-	}
+		if err := envtest.WaitForCRDs(h.config, crds, envtest.CRDInstallOptions{
+			PollInterval: 100 * time.Millisecond,
+			MaxTime:      10 * time.Second,
+		}); err != nil {
+			h.fatal(fmt.Errorf("fatal error waiting for crds: %v", err))
+		}
 
-	// Create a new client to bust the client's CRD cache.
-	cl, err = h.Client(true)
-	if err != nil {
-		h.fatal(fmt.Errorf("fatal error getting client after crd update: %v", err))
+		// Create a new client to bust the client's CRD cache.
+		cl, err = h.Client(true)
+		if err != nil {
+			h.fatal(fmt.Errorf("fatal error getting client after crd update: %v", err))
+		}
 	}
 
 	// Install required manifests.
@@ -502,22 +680,103 @@ func (h *Harness) Setup() {
 			h.fatal(fmt.Errorf("fatal error installing manifests: %v", err))
 		}
 	}
-	bgs, err := testutils.RunCommands(context.TODO(), h.GetLogger(), "default", h.TestSuite.Commands, "", h.TestSuite.Timeout, "")
+	commands, secrets, err := testutils.ResolveEnv(context.TODO(), h.Client, "default", "", nil, h.TestSuite.Commands)
+	if err != nil {
+		h.fatal(fmt.Errorf("fatal error resolving environment variables of commands: %v", err))
+	}
+	bgs, err := testutils.RunCommandsWithOptions(context.TODO(), testutils.NewMaskingLogger(h.GetLogger(), secrets), "default", commands, "", h.TestSuite.Timeout, "", testutils.CommandOptions{Variables: h.variables, TestInfo: h.testInfo(), Client: h.Client, DiscoveryClient: h.DiscoveryClient})
 	// assign any background processes first for cleanup in case of any errors
 	h.bgProcesses = append(h.bgProcesses, bgs...)
 	if err != nil {
 		h.fatal(fmt.Errorf("fatal error running commands: %v", err))
 	}
+
+	if err := validateHooks(&h.TestSuite); err != nil {
+		h.fatal(fmt.Errorf("fatal error in lifecycle hooks: %v", err))
+	}
+	// afterAll is run by Stop from now on, even if beforeAll or the tests fail
+	h.afterAll = h.TestSuite.AfterAll
+
+	if h.TestSuite.BeforeAll != nil {
+		if errs := h.runSuiteHook("beforeAll", h.TestSuite.BeforeAll); len(errs) > 0 {
+			h.fatal(fmt.Errorf("fatal error running beforeAll: %v", errs[len(errs)-1]))
+		}
+	}
+}
+
+// runSuiteHook runs a lifecycle hook of the test suite in the namespace of the test suite, or the default namespace
+// if none is set, and adds its outcome to the report.
+func (h *Harness) runSuiteHook(name string, hook *harness.LifecycleHook) []error {
+	applyOptions, err := h.applyOptions()
+	if err != nil {
+		return []error{err}
+	}
+
+	// the hook does not belong to a test case, so it has no name and directory
+	hookCase := &Case{
+		Suite:              h.TestSuite.Name,
+		Timeout:            h.GetTimeout(),
+		SkipDelete:         h.TestSuite.SkipDelete,
+		RunLabels:          h.RunLabels,
+		ArtifactsDir:       h.TestSuite.ArtifactsDir,
+		SemanticComparison: h.TestSuite.SemanticComparison,
+		ApplyOptions:       applyOptions,
+		Offline:            h.TestSuite.Offline,
+		ExpandVariables:    h.TestSuite.ExpandVariables,
+		Client:             h.Client,
+		DiscoveryClient:    h.DiscoveryClient,
+		KubernetesClient:   h.KubernetesClient,
+		OfflineCluster:     h.OfflineCluster,
+		Logger:             testutils.NewTestLogger(h.T, ""),
+		// the outputs of beforeAll are passed to the test cases
+		variables: h.variables,
+	}
+
+	ns := h.TestSuite.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+
+	if h.hookReport == nil {
+		h.hookReport = h.report.NewSuite("lifecycle")
+	}
+	tc := report.NewCase(name)
+
+	bgs, errs := hookCase.runHook(h.T, name, hook, &namespace{Name: ns})
+	// assign any background processes first for cleanup in case of any errors
+	h.bgProcesses = append(h.bgProcesses, bgs...)
+
+	if len(errs) > 0 {
+		tc.Failure = report.NewFailure(fmt.Sprintf("failed in %s", name), errs)
+	}
+	h.hookReport.AddTestcase(tc)
+
+	return errs
 }
 
 // Stop the test environment and clean up the harness.
 func (h *Harness) Stop() {
 	h.T.Log("cleaning up")
+	if h.afterAll != nil {
+		afterAll := h.afterAll
+		h.afterAll = nil
+		if errs := h.runSuiteHook("afterAll", afterAll); len(errs) > 0 {
+			h.T.Error("failed in afterAll")
+			for _, err := range errs {
+				h.T.Error(err)
+			}
+		}
+	}
+
 	if h.managerStopCh != nil {
 		close(h.managerStopCh)
 		h.managerStopCh = nil
 	}
 
+	if h.watchCache != nil {
+		h.watchCache.Stop()
+	}
+
 	if h.kind != nil {
 		logDir := filepath.Join(h.TestSuite.ArtifactsDir, fmt.Sprintf("kind-logs-%d", time.Now().Unix()))
 
@@ -612,6 +871,12 @@ func (h *Harness) Report() {
 	}
 }
 
+// testInfo returns the metadata of the test suite which is exported to its commands, i.e. that of a test case
+// without name and directories.
+func (h *Harness) testInfo() *testutils.TestInfo {
+	return (&Case{Suite: h.TestSuite.Name, RunLabels: h.RunLabels, ArtifactsDir: h.TestSuite.ArtifactsDir}).testInfo()
+}
+
 // reportName returns the configured ReportName.
 func (h *Harness) reportName() string {
 	if h.TestSuite.ReportName != "" {
@@ -4,11 +4,19 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	volumetypes "github.com/docker/docker/api/types/volume"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	kindConfig "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
 )
 
 func TestGetTimeout(t *testing.T) {
@@ -19,6 +27,20 @@ func TestGetTimeout(t *testing.T) {
 	assert.Equal(t, 45, h.GetTimeout())
 }
 
+func TestWatchCacheOptIn(t *testing.T) {
+	// without a cluster, creating a watch cache would fail, so nil means that none is created
+	h := Harness{T: t}
+	watchCache, err := h.WatchCache()
+	require.NoError(t, err)
+	assert.Nil(t, watchCache)
+
+	h.TestSuite.WatchCache = true
+	h.TestSuite.Offline = true
+	watchCache, err = h.WatchCache()
+	require.NoError(t, err)
+	assert.Nil(t, watchCache)
+}
+
 func TestGetReportName(t *testing.T) {
 	h := Harness{}
 	assert.Equal(t, "kuttl-report", h.reportName())
@@ -88,3 +110,103 @@ func TestAddNodeCaches(t *testing.T) {
 	assert.Equal(t, "/var/lib/docker/data/kind-0", kindCfg.Nodes[0].ExtraMounts[0].HostPath)
 	assert.Equal(t, "/var/lib/docker/data/kind-1", kindCfg.Nodes[1].ExtraMounts[0].HostPath)
 }
+
+func TestLoadTestsMatrix(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "plain"), 0700))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "zookeeper"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zookeeper", harness.MatrixFile), []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestMatrix
+dimensions:
+- name: VERSION
+  values: ["3.8", "3.9"]
+- name: TLS_ENABLED
+  values: ["true", "false"]
+`), 0600))
+
+	h := Harness{T: t, variables: map[string]string{"SUITE": "suite"}}
+	tests, err := h.LoadTests(dir)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, test := range tests {
+		names = append(names, test.Name)
+		assert.Equal(t, "suite", test.Variables["SUITE"], test.Name)
+	}
+	assert.Equal(t, []string{
+		"plain",
+		"zookeeper_version-3-8_tls-enabled-true",
+		"zookeeper_version-3-8_tls-enabled-false",
+		"zookeeper_version-3-9_tls-enabled-true",
+		"zookeeper_version-3-9_tls-enabled-false",
+	}, names)
+	assert.Equal(t, map[string]string{"SUITE": "suite", "VERSION": "3.9", "TLS_ENABLED": "true"}, tests[3].Variables)
+	assert.Equal(t, filepath.Join(dir, "zookeeper"), tests[3].Dir)
+
+	// the suite variables are not changed by the matrix
+	assert.Equal(t, map[string]string{"SUITE": "suite"}, h.variables)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zookeeper", harness.MatrixFile), []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestMatrix
+dimensions:
+- name: TLS
+`), 0600))
+	_, err = h.LoadTests(dir)
+	assert.ErrorContains(t, err, "dimension TLS requires values")
+
+	// values which only differ in characters which are not allowed in DNS labels would run as the same test
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zookeeper", harness.MatrixFile), []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestMatrix
+dimensions:
+- name: VERSION
+  values: ["3.9", "3-9"]
+`), 0600))
+	_, err = h.LoadTests(dir)
+	assert.EqualError(t, err, "matrix of test zookeeper has more than one combination named zookeeper_version-3-9")
+
+	// the combinations can not share the namespace of the test suite
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zookeeper", harness.MatrixFile), []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestMatrix
+dimensions:
+- name: VERSION
+  values: ["3.8", "3.9"]
+`), 0600))
+	h.TestSuite.Namespace = "zookeeper"
+	_, err = h.LoadTests(dir)
+	assert.EqualError(t, err, "test zookeeper has a matrix, which can not be run in the namespace zookeeper of the test suite")
+}
+
+func TestDNSLabel(t *testing.T) {
+	for value, expected := range map[string]string{
+		"3.9.2":                        "3-9-2",
+		"TLS_ENABLED":                  "tls-enabled",
+		"quay.io/zookeeper:3.9":        "quay-io-zookeeper-3-9",
+		"  leading and trailing  ":     "leading-and-trailing",
+		strings.Repeat("a", 70):        strings.Repeat("a", 63),
+		strings.Repeat("a", 62) + ".b": strings.Repeat("a", 62),
+	} {
+		assert.Equal(t, expected, dnsLabel(value), value)
+	}
+}
+
+func TestSetupCommandTestInfo(t *testing.T) {
+	artifactsDir := t.TempDir()
+
+	h := Harness{
+		T:         t,
+		RunLabels: labels.Set{"flavor": "a"},
+		TestSuite: harness.TestSuite{
+			ObjectMeta:   metav1.ObjectMeta{Name: "e2e"},
+			Offline:      true,
+			ArtifactsDir: artifactsDir,
+			Commands: []harness.Command{{
+				Script: `echo "$KUTTL_SUITE|$KUTTL_SUITE_DIR|$KUTTL_TEST|$KUTTL_TEST_DIR|$KUTTL_STEP_INDEX|$KUTTL_STEP_NAME|$KUTTL_ARTIFACTS_DIR|$KUTTL_RUN_LABELS"`,
+				Output: &harness.CommandOutput{Name: "INFO"},
+			}},
+		},
+	}
+	h.Setup()
+
+	// the commands of the test suite do not belong to a test directory, test case or step
+	assert.Equal(t, "e2e||||||"+artifactsDir+"|flavor=a", h.variables["INFO"])
+}
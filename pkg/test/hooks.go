@@ -0,0 +1,97 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/stackabletech/kuttl/pkg/report"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+// runHook runs the commands and then the test steps of a lifecycle hook in namespace ns, stopping at the first
+// failure. The test steps are loaded from hook.Steps like the steps of a test case. The processes of background
+// commands are returned, also if the hook failed.
+func (t *Case) runHook(test *testing.T, name string, hook *harness.LifecycleHook, ns *namespace) ([]*exec.Cmd, []error) {
+	logger := t.Logger.WithPrefix(name)
+
+	commands, secrets, err := testutils.ResolveEnv(context.TODO(), t.Client, ns.Name, "", nil, hook.Commands)
+	if err != nil {
+		return nil, []error{err}
+	}
+	bgs, err := testutils.RunCommandsWithOptions(context.TODO(), testutils.NewMaskingLogger(logger, secrets), ns.Name, commands, "", t.Timeout, "", testutils.CommandOptions{Variables: t.variables, TestInfo: t.testInfo(), Client: t.Client, DiscoveryClient: t.DiscoveryClient})
+	if err != nil {
+		return bgs, []error{err}
+	}
+
+	if hook.Steps == "" {
+		return bgs, nil
+	}
+
+	testSteps, err := t.loadTestSteps(hook.Steps)
+	if err != nil {
+		return bgs, []error{fmt.Errorf("failed to load steps from %s: %w", hook.Steps, err)}
+	}
+
+	for _, testStep := range testSteps {
+		testStep.Logger = logger.WithPrefix(testStep.String())
+
+		errs := t.setupStep(test, testStep, ns)
+		if len(errs) == 0 {
+			errs = testStep.Run(test, ns.Name)
+		}
+		if len(errs) > 0 {
+			return bgs, append([]error{fmt.Errorf("failed in step %s", testStep.String())}, errs...)
+		}
+	}
+
+	return bgs, nil
+}
+
+// reportHook runs a lifecycle hook, if it is set, and adds its outcome to ts as a testcase called name.
+// It returns false if the hook failed.
+func (t *Case) reportHook(test *testing.T, ts *report.Testsuite, name string, hook *harness.LifecycleHook, ns *namespace) bool {
+	if hook == nil {
+		return true
+	}
+
+	tc := report.NewCase(name)
+	_, errs := t.runHook(test, name, hook, ns)
+	if len(errs) > 0 {
+		hookErr := fmt.Errorf("failed in %s", name)
+		tc.Failure = report.NewFailure(hookErr.Error(), errs)
+
+		test.Error(hookErr)
+		for _, err := range errs {
+			test.Error(err)
+		}
+	}
+	ts.AddTestcase(tc)
+
+	return len(errs) == 0
+}
+
+// validateHooks checks the lifecycle hooks of a test suite. Only beforeAll can run commands in the background, the
+// processes of which are stopped with the test suite.
+func validateHooks(suite *harness.TestSuite) error {
+	hooks := []struct {
+		name string
+		hook *harness.LifecycleHook
+	}{
+		{name: "beforeAll", hook: suite.BeforeAll},
+		{name: "afterAll", hook: suite.AfterAll},
+		{name: "beforeEach", hook: suite.BeforeEach},
+		{name: "afterEach", hook: suite.AfterEach},
+	}
+	for _, h := range hooks {
+		if h.hook == nil {
+			continue
+		}
+		if err := h.hook.Validate(h.name == "beforeAll"); err != nil {
+			return fmt.Errorf("invalid %s: %w", h.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package test
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/stackabletech/kuttl/pkg/report"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+// writeHookSteps writes a step directory which creates the ConfigMap "hook".
+func writeHookSteps(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00-configmap.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: hook
+data:
+  created: "true"
+`), 0600))
+	return dir
+}
+
+func TestCaseRunHooks(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	c := Case{
+		Name:       "hooks",
+		Logger:     testutils.NewTestLogger(t, ""),
+		SkipDelete: true,
+		Suppress:   []string{"events"},
+		Timeout:    1,
+		BeforeEach: &harness.LifecycleHook{
+			Commands: []harness.Command{{Command: "true"}},
+			Steps:    writeHookSteps(t),
+		},
+		AfterEach: &harness.LifecycleHook{
+			Commands: []harness.Command{{Command: "true"}},
+		},
+		Steps: []*Step{
+			{
+				Name:    "assert-hook",
+				Index:   0,
+				Asserts: []client.Object{testutils.NewResource("v1", "ConfigMap", "hook", "")},
+				Timeout: 1,
+			},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	ts := &report.Testsuite{}
+	c.Run(t, ts)
+
+	names := []string{}
+	for _, tc := range ts.Testcases {
+		names = append(names, tc.Name)
+		assert.Nil(t, tc.Failure, tc.Name)
+	}
+	assert.Equal(t, []string{"setup", "beforeEach", "step 0-assert-hook", "afterEach"}, names)
+}
+
+func TestRunHookFailure(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	c := Case{
+		Logger:          testutils.NewTestLogger(t, ""),
+		SkipDelete:      true,
+		Timeout:         1,
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	hook := &harness.LifecycleHook{
+		Commands: []harness.Command{{Command: "false"}},
+		Steps:    writeHookSteps(t),
+	}
+	_, errs := c.runHook(t, "beforeEach", hook, &namespace{Name: testNamespace})
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "exit status 1")
+
+	// the steps are not run after a failed command
+	err := cl.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: "hook"}, &corev1.ConfigMap{})
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	hook.Steps = filepath.Join(t.TempDir(), "missing")
+	hook.Commands = nil
+	_, errs = c.runHook(t, "beforeEach", hook, &namespace{Name: testNamespace})
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "failed to load steps from "+hook.Steps)
+}
+
+// suiteHooksResult is the outcome of a test suite run by runSuiteWithHooks.
+type suiteHooksResult struct {
+	TestRan     bool
+	AfterAllRan bool
+	// Testcases maps the testcases of the JUnit report, as "suite/testcase", to their failure messages.
+	Testcases map[string]string
+}
+
+// runSuiteWithHooks runs an offline test suite with a single test case, which fails if failTest is set, and with
+// all lifecycle hooks, of which beforeAll runs beforeAllCommand.
+func runSuiteWithHooks(t *testing.T, beforeAllCommand string, failTest bool) suiteHooksResult {
+	dir := t.TempDir()
+	artifactsDir := t.TempDir()
+	testMarker := filepath.Join(dir, "test-ran")
+	afterAllMarker := filepath.Join(dir, "after-all-ran")
+
+	testDir := filepath.Join(dir, "tests", "hooked")
+	require.NoError(t, os.MkdirAll(testDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "00-mark.yaml"), []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestStep
+commands:
+- script: touch `+testMarker+`
+`), 0600))
+	if failTest {
+		require.NoError(t, os.WriteFile(filepath.Join(testDir, "00-assert.yaml"), []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestAssert
+timeout: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: missing
+`), 0600))
+	}
+
+	// the harness ends the test when beforeAll fails, so it is run in a subtest
+	t.Run("suite", func(t *testing.T) {
+		h := Harness{
+			T: t,
+			TestSuite: harness.TestSuite{
+				Offline:      true,
+				TestDirs:     []string{filepath.Join(dir, "tests")},
+				ArtifactsDir: artifactsDir,
+				ReportFormat: string(report.XML),
+				Suppress:     []string{"events"},
+				BeforeAll:    &harness.LifecycleHook{Commands: []harness.Command{{Script: beforeAllCommand}}},
+				AfterAll:     &harness.LifecycleHook{Commands: []harness.Command{{Script: "touch " + afterAllMarker}}},
+				BeforeEach:   &harness.LifecycleHook{Commands: []harness.Command{{Command: "true"}}},
+				AfterEach:    &harness.LifecycleHook{Commands: []harness.Command{{Command: "true"}}},
+			},
+		}
+		h.Run()
+	})
+
+	result := suiteHooksResult{Testcases: map[string]string{}}
+	_, err := os.Stat(testMarker)
+	result.TestRan = err == nil
+	_, err = os.Stat(afterAllMarker)
+	result.AfterAllRan = err == nil
+
+	data, err := os.ReadFile(filepath.Join(artifactsDir, "kuttl-report.xml"))
+	require.NoError(t, err)
+	junit := &report.Testsuites{}
+	require.NoError(t, xml.Unmarshal(data, junit))
+
+	var addTestcases func(suites []*report.Testsuite)
+	addTestcases = func(suites []*report.Testsuite) {
+		for _, suite := range suites {
+			for _, tc := range suite.Testcases {
+				result.Testcases[filepath.Base(suite.Name)+"/"+tc.Name] = ""
+				if tc.Failure != nil {
+					result.Testcases[filepath.Base(suite.Name)+"/"+tc.Name] = tc.Failure.Message
+				}
+			}
+			addTestcases(suite.SubSuites)
+		}
+	}
+	addTestcases(junit.Testsuite)
+	return result
+}
+
+func TestHarnessHooks(t *testing.T) {
+	t.Run("afterAll after failing test", func(t *testing.T) {
+		result := suiteHooksResult{}
+		runFailing(t, func(t *testing.T) interface{} {
+			return runSuiteWithHooks(t, "true", true)
+		}, &result)
+
+		assert.True(t, result.TestRan)
+		assert.True(t, result.AfterAllRan)
+		assert.Equal(t, map[string]string{
+			"lifecycle/beforeAll": "",
+			"lifecycle/afterAll":  "",
+			"hooked/setup":        "",
+			"hooked/beforeEach":   "",
+			"hooked/afterEach":    "",
+			"hooked/step 0-mark":  "failed in step 0-mark",
+		}, result.Testcases)
+	})
+
+	t.Run("failing beforeAll", func(t *testing.T) {
+		result := suiteHooksResult{}
+		runFailing(t, func(t *testing.T) interface{} {
+			return runSuiteWithHooks(t, "exit 1", false)
+		}, &result)
+
+		assert.False(t, result.TestRan, "the tests must not run after beforeAll failed")
+		assert.True(t, result.AfterAllRan)
+		assert.Equal(t, map[string]string{
+			"lifecycle/beforeAll": "failed in beforeAll",
+			"lifecycle/afterAll":  "",
+		}, result.Testcases)
+	})
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"k8s.io/client-go/rest"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+// maxHTTPBodyInError is the number of bytes of a response body that are included in error messages.
+const maxHTTPBodyInError = 512
+
+// CheckAssertHTTP checks the HTTP assertions of the step's TestAssert, returning an error for every failed assertion.
+// Each request is limited to timeout seconds.
+func (s *Step) CheckAssertHTTP(namespace string, timeout int) []error {
+	if s.Assert == nil || len(s.Assert.HTTP) == 0 {
+		return nil
+	}
+
+	kClient, err := s.KubernetesClient()
+	if err != nil {
+		return []error{err}
+	}
+	restClient, ok := kClient.CoreV1().RESTClient().(*rest.RESTClient)
+	if !ok || restClient == nil {
+		return []error{errors.New("http assertions require a Kubernetes client connected to an API server")}
+	}
+
+	// the paths and regexes are compiled when the TestAssert is loaded, but not if it was set otherwise
+	if len(s.httpRequests) != len(s.Assert.HTTP) {
+		requests := make([]httpRequest, len(s.Assert.HTTP))
+		for i := range s.Assert.HTTP {
+			if requests[i].path, requests[i].bodyRegex, err = s.Assert.HTTP[i].Compile(); err != nil {
+				return []error{fmt.Errorf("invalid http assertion %d: %w", i, err)}
+			}
+		}
+		s.httpRequests = requests
+	}
+
+	testErrors := []error{}
+	for i := range s.Assert.HTTP {
+		httpAssert := &s.Assert.HTTP[i]
+		if err := checkHTTP(restClient, httpAssert, s.httpRequests[i], namespace, timeout); err != nil {
+			testErrors = append(testErrors, fmt.Errorf("http assertion %s: %w", httpAssert.String(), err))
+		}
+	}
+	return testErrors
+}
+
+// httpRequest is the parsed path and the compiled body regex of an HTTP assertion.
+type httpRequest struct {
+	path      *url.URL
+	bodyRegex *regexp.Regexp
+}
+
+func checkHTTP(restClient *rest.RESTClient, httpAssert *harness.TestHTTPAssert, request httpRequest, namespace string, timeout int) error {
+	if httpAssert.Namespace != "" {
+		namespace = httpAssert.Namespace
+	}
+
+	resource, name := "services", httpAssert.Service
+	if httpAssert.Pod != "" {
+		resource, name = "pods", httpAssert.Pod
+	}
+	// the proxy subresource addresses the port of a service or pod as [scheme:]name:port
+	name = fmt.Sprintf("%s:%s", name, httpAssert.Port.String())
+	if httpAssert.Scheme != "" {
+		name = fmt.Sprintf("%s:%s", httpAssert.Scheme, name)
+	}
+
+	req := restClient.Verb(httpAssert.GetMethod()).Namespace(namespace).Resource(resource).Name(name).SubResource("proxy").Suffix(request.path.Path)
+	for key, values := range request.path.Query() {
+		for _, value := range values {
+			req = req.Param(key, value)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Duration(max(timeout, 1))*time.Second)
+	defer cancel()
+
+	// rest.Result does not expose the response headers, so the request is sent with the HTTP client of the REST client
+	httpReq, err := http.NewRequestWithContext(ctx, httpAssert.GetMethod(), req.URL().String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := restClient.Client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != httpAssert.GetStatusCode() {
+		return fmt.Errorf("expected status code %d, got %d: %s", httpAssert.GetStatusCode(), resp.StatusCode, truncate(body, maxHTTPBodyInError))
+	}
+	for header, expected := range httpAssert.Headers {
+		if actual := resp.Header.Get(header); actual != expected {
+			return fmt.Errorf("expected header %s to be %q, got %q", header, expected, actual)
+		}
+	}
+	if request.bodyRegex != nil && !request.bodyRegex.Match(body) {
+		return fmt.Errorf("body does not match regex %q: %s", httpAssert.BodyRegex, truncate(body, maxHTTPBodyInError))
+	}
+	if httpAssert.JSONBody != nil {
+		var expected, actual interface{}
+		if err := json.Unmarshal(httpAssert.JSONBody.Raw, &expected); err != nil {
+			return fmt.Errorf("invalid jsonBody: %w", err)
+		}
+		if err := json.Unmarshal(body, &actual); err != nil {
+			return fmt.Errorf("body is not valid JSON: %w: %s", err, truncate(body, maxHTTPBodyInError))
+		}
+		if err := testutils.IsSubset(expected, actual); err != nil {
+			return fmt.Errorf("body does not match jsonBody: %w", err)
+		}
+	}
+	return nil
+}
+
+// truncate returns b as a string, shortened to at most n bytes.
+func truncate(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
+	}
+	return string(b[:n]) + "..."
+}
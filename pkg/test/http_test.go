@@ -0,0 +1,157 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+func TestCheckAssertHTTP(t *testing.T) {
+	// stands in for the proxy subresource of the API server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/world/services/web:http/proxy/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status": "UP", "checks": [{"name": "db", "status": "UP"}], "method": %q, "verbose": %q}`, r.Method, r.URL.Query().Get("verbose"))
+	})
+	mux.HandleFunc("/api/v1/namespaces/other/pods/https:web-0:8443/proxy/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "starting")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	service := func(path string) harness.TestHTTPAssert {
+		return harness.TestHTTPAssert{Service: "web", Port: intstr.FromString("http"), Path: path}
+	}
+	withJSONBody := func(httpAssert harness.TestHTTPAssert, body string) harness.TestHTTPAssert {
+		httpAssert.JSONBody = &apiextv1.JSON{Raw: []byte(body)}
+		return httpAssert
+	}
+
+	for _, tt := range []struct {
+		name       string
+		httpAssert harness.TestHTTPAssert
+		err        string
+	}{
+		{
+			name:       "status code",
+			httpAssert: service("/healthz"),
+		},
+		{
+			name:       "unexpected status code",
+			httpAssert: harness.TestHTTPAssert{Pod: "web-0", Namespace: "other", Port: intstr.FromInt32(8443), Scheme: "https"},
+			err:        "http assertion [pod==web-0:8443,namespace: other,GET /]: expected status code 200, got 503: starting",
+		},
+		{
+			name:       "expected status code",
+			httpAssert: harness.TestHTTPAssert{Pod: "web-0", Namespace: "other", Port: intstr.FromInt32(8443), Scheme: "https", StatusCode: 503},
+		},
+		{
+			name:       "not found",
+			httpAssert: service("/ready"),
+			err:        "http assertion [service==web:http,GET /ready]: expected status code 200, got 404: 404 page not found\n",
+		},
+		{
+			name: "headers",
+			httpAssert: func() harness.TestHTTPAssert {
+				httpAssert := service("/healthz")
+				httpAssert.Headers = map[string]string{"content-type": "application/json"}
+				return httpAssert
+			}(),
+		},
+		{
+			name: "unexpected header",
+			httpAssert: func() harness.TestHTTPAssert {
+				httpAssert := service("/healthz")
+				httpAssert.Headers = map[string]string{"Content-Type": "text/plain"}
+				return httpAssert
+			}(),
+			err: `http assertion [service==web:http,GET /healthz]: expected header Content-Type to be "text/plain", got "application/json"`,
+		},
+		{
+			name: "method and query",
+			httpAssert: func() harness.TestHTTPAssert {
+				httpAssert := service("/healthz?verbose=true")
+				httpAssert.Method = "post"
+				httpAssert.BodyRegex = `"method": "POST", "verbose": "true"`
+				return httpAssert
+			}(),
+		},
+		{
+			name: "body regex",
+			httpAssert: func() harness.TestHTTPAssert {
+				httpAssert := service("/healthz")
+				httpAssert.BodyRegex = `"status": "DOWN"`
+				return httpAssert
+			}(),
+			err: `http assertion [service==web:http,GET /healthz]: body does not match regex "\"status\": \"DOWN\"": {"status": "UP", "checks": [{"name": "db", "status": "UP"}], "method": "GET", "verbose": ""}`,
+		},
+		{
+			name:       "json body",
+			httpAssert: withJSONBody(service("/healthz"), `{"status": "UP", "checks": [{"name": "db"}]}`),
+		},
+		{
+			name:       "json body mismatch",
+			httpAssert: withJSONBody(service("/healthz"), `{"checks": [{"status": "DOWN"}]}`),
+			err:        "http assertion [service==web:http,GET /healthz]: body does not match jsonBody: .checks.status: value mismatch, expected: DOWN != actual: UP",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.httpAssert.Validate())
+
+			step := Step{
+				Assert:           &harness.TestAssert{HTTP: []harness.TestHTTPAssert{tt.httpAssert}},
+				KubernetesClient: func() (kubernetes.Interface, error) { return kubernetes.NewForConfig(&rest.Config{Host: srv.URL}) },
+				Logger:           testutils.NewTestLogger(t, ""),
+			}
+
+			errs := step.CheckAssertHTTP(testNamespace, 5)
+			if tt.err == "" {
+				assert.Empty(t, errs)
+			} else {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckAssertHTTPWithoutAPIServer(t *testing.T) {
+	step := Step{
+		Assert:           &harness.TestAssert{HTTP: []harness.TestHTTPAssert{{Service: "web", Port: intstr.FromInt32(80)}}},
+		KubernetesClient: func() (kubernetes.Interface, error) { return kfake.NewSimpleClientset(), nil },
+		Logger:           testutils.NewTestLogger(t, ""),
+	}
+
+	errs := step.CheckAssertHTTP(testNamespace, 5)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "http assertions require a Kubernetes client connected to an API server")
+}
+
+func TestCheckAssertHTTPInvalid(t *testing.T) {
+	// HTTP assertions which were not validated when they were loaded are reported, before any request is sent
+	step := Step{
+		Assert: &harness.TestAssert{HTTP: []harness.TestHTTPAssert{{Service: "web", Port: intstr.FromInt32(80), BodyRegex: "("}}},
+		KubernetesClient: func() (kubernetes.Interface, error) {
+			return kubernetes.NewForConfig(&rest.Config{Host: "http://localhost:1"})
+		},
+		Logger: testutils.NewTestLogger(t, ""),
+	}
+
+	errs := step.CheckAssertHTTP(testNamespace, 5)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "invalid http assertion 0: http assertion has invalid body regex \"(\": error parsing regexp: missing closing ): `(`")
+}
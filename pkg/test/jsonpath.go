@@ -0,0 +1,95 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+// CheckAssertJSONPaths checks the JSONPath assertions of the step's TestAssert, returning an error for every failed assertion.
+func (s *Step) CheckAssertJSONPaths(namespace string) []error {
+	if s.Assert == nil || len(s.Assert.JSONPath) == 0 {
+		return nil
+	}
+
+	cl, err := s.checkClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	// the paths are parsed when the TestAssert is loaded, but not if it was set otherwise
+	if len(s.jsonPaths) != len(s.Assert.JSONPath) {
+		parsers := make([]*jsonpath.JSONPath, len(s.Assert.JSONPath))
+		for i := range s.Assert.JSONPath {
+			if parsers[i], err = s.Assert.JSONPath[i].Compile(); err != nil {
+				return []error{fmt.Errorf("invalid jsonpath assertion %d: %w", i, err)}
+			}
+		}
+		s.jsonPaths = parsers
+	}
+
+	testErrors := []error{}
+	for i := range s.Assert.JSONPath {
+		jsonPathAssert := &s.Assert.JSONPath[i]
+
+		obj := testutils.NewResource(jsonPathAssert.APIVersion, jsonPathAssert.Kind, jsonPathAssert.Name, jsonPathAssert.Namespace)
+		name, objNs, err := testutils.Namespaced(dClient, obj, namespace)
+		if err != nil {
+			testErrors = append(testErrors, err)
+			continue
+		}
+
+		actuals := []unstructured.Unstructured{}
+		if name != "" {
+			if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: objNs, Name: name}, obj); err != nil {
+				testErrors = append(testErrors, err)
+				continue
+			}
+			actuals = append(actuals, *obj)
+		} else {
+			if actuals, err = list(cl, obj.GroupVersionKind(), objNs, jsonPathAssert.Labels); err != nil {
+				testErrors = append(testErrors, err)
+				continue
+			}
+			if len(actuals) == 0 {
+				testErrors = append(testErrors, fmt.Errorf("no resources matched of kind: %s", obj.GroupVersionKind().String()))
+				continue
+			}
+		}
+
+		testErrors = append(testErrors, checkJSONPath(jsonPathAssert, s.jsonPaths[i], actuals)...)
+	}
+	return testErrors
+}
+
+// checkJSONPath evaluates the JSONPath assertion with its parsed path against the actual objects. It returns no
+// errors if any of them satisfies the assertion, otherwise an error for each of them.
+func checkJSONPath(jsonPathAssert *harness.TestJSONPathAssert, parser *jsonpath.JSONPath, actuals []unstructured.Unstructured) []error {
+	testErrors := []error{}
+	for i := range actuals {
+		actual := &actuals[i]
+
+		buf := &bytes.Buffer{}
+		if err := parser.Execute(buf, actual.UnstructuredContent()); err != nil {
+			testErrors = append(testErrors, fmt.Errorf("resource %s: jsonpath %s: %w", testutils.ResourceID(actual), jsonPathAssert.Path, err))
+			continue
+		}
+		if buf.String() == jsonPathAssert.Value {
+			return nil
+		}
+		testErrors = append(testErrors, fmt.Errorf("resource %s: jsonpath %s: expected %q, got %q", testutils.ResourceID(actual), jsonPathAssert.Path, jsonPathAssert.Value, buf.String()))
+	}
+	return testErrors
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+func TestCheckAssertJSONPaths(t *testing.T) {
+	newPod := func(name string, sidecarReady bool) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Labels: map[string]string{"app": "zk"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}, {Name: "sidecar"}}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "main", Ready: true},
+					{Name: "sidecar", Ready: sidecarReady},
+				},
+			},
+		}
+	}
+
+	sidecarReady := `{.status.containerStatuses[?(@.name=="sidecar")].ready}`
+
+	for _, tt := range []struct {
+		name   string
+		assert harness.TestJSONPathAssert
+		errs   []string
+	}{
+		{
+			name:   "filter in array",
+			assert: harness.TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "ready", Path: sidecarReady, Value: "true"},
+		},
+		{
+			name:   "unexpected value",
+			assert: harness.TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "unready", Path: sidecarReady, Value: "true"},
+			errs:   []string{`resource Pod:world/unready: jsonpath ` + sidecarReady + `: expected "true", got "false"`},
+		},
+		{
+			name:   "path without braces",
+			assert: harness.TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "ready", Path: ".spec.containers[*].name", Value: "main sidecar"},
+		},
+		{
+			name:   "missing key",
+			assert: harness.TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "ready", Path: ".status.phase", Value: "Running"},
+			errs:   []string{`resource Pod:world/ready: jsonpath .status.phase: expected "Running", got ""`},
+		},
+		{
+			name:   "any listed resource",
+			assert: harness.TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Labels: map[string]string{"app": "zk"}, Path: sidecarReady, Value: "false"},
+		},
+		{
+			name:   "no listed resource",
+			assert: harness.TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Labels: map[string]string{"app": "hdfs"}, Path: sidecarReady, Value: "true"},
+			errs:   []string{"no resources matched of kind: /v1, Kind=Pod"},
+		},
+		{
+			name:   "resource does not exist",
+			assert: harness.TestJSONPathAssert{APIVersion: "v1", Kind: "Pod", Name: "other", Path: sidecarReady, Value: "true"},
+			errs:   []string{`pods "other" not found`},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.assert.Validate())
+
+			step := Step{
+				Assert: &harness.TestAssert{JSONPath: []harness.TestJSONPathAssert{tt.assert}},
+				Logger: testutils.NewTestLogger(t, ""),
+				Client: func(bool) (client.Client, error) {
+					return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(newPod("ready", true), newPod("unready", false)).Build(), nil
+				},
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+
+			errs := step.CheckAssertJSONPaths(testNamespace)
+			assert.Len(t, errs, len(tt.errs))
+			for i := range errs {
+				if i < len(tt.errs) {
+					assert.EqualError(t, errs[i], tt.errs[i])
+				}
+			}
+		})
+	}
+}
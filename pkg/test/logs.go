@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// CheckAssertLogs checks the log assertions of the step's TestAssert, returning an error for every failed assertion.
+func (s *Step) CheckAssertLogs(namespace string) []error {
+	if s.Assert == nil || len(s.Assert.Logs) == 0 {
+		return nil
+	}
+
+	kClient, err := s.KubernetesClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	// the regexes are compiled when the TestAssert is loaded, but not if it was set otherwise
+	if len(s.logRegexes) != len(s.Assert.Logs) {
+		regexes, err := compileLogRegexes(s.Assert.Logs)
+		if err != nil {
+			return []error{err}
+		}
+		s.logRegexes = regexes
+	}
+
+	testErrors := []error{}
+	for i := range s.Assert.Logs {
+		logAssert := &s.Assert.Logs[i]
+		if err := checkLogs(context.TODO(), kClient, logAssert, s.logRegexes[i], namespace); err != nil {
+			testErrors = append(testErrors, fmt.Errorf("log assertion %s: %w", logAssert.String(), err))
+		}
+	}
+	return testErrors
+}
+
+// logRegexes are the compiled regexes of a log assertion.
+type logRegexes struct {
+	regex, notRegex *regexp.Regexp
+}
+
+// compileLogRegexes compiles the regexes of the log assertions, by index.
+func compileLogRegexes(logAsserts []harness.TestLogAssert) ([]logRegexes, error) {
+	regexes := make([]logRegexes, len(logAsserts))
+	for i := range logAsserts {
+		regex, notRegex, err := logAsserts[i].Compile()
+		if err != nil {
+			return nil, fmt.Errorf("invalid log assertion %d: %w", i, err)
+		}
+		regexes[i] = logRegexes{regex: regex, notRegex: notRegex}
+	}
+	return regexes, nil
+}
+
+func checkLogs(ctx context.Context, kClient kubernetes.Interface, logAssert *harness.TestLogAssert, regexes logRegexes, namespace string) error {
+	if logAssert.Namespace != "" {
+		namespace = logAssert.Namespace
+	}
+
+	var pods []corev1.Pod
+	if logAssert.Pod != "" {
+		pod, err := kClient.CoreV1().Pods(namespace).Get(ctx, logAssert.Pod, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		pods = append(pods, *pod)
+	} else {
+		podList, err := kClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: logAssert.Selector})
+		if err != nil {
+			return err
+		}
+		if len(podList.Items) == 0 {
+			return fmt.Errorf("no pods matched selector %q in namespace %s", logAssert.Selector, namespace)
+		}
+		pods = podList.Items
+	}
+
+	regex, notRegex := regexes.regex, regexes.notRegex
+	containsFound, regexFound := false, false
+	for _, pod := range pods {
+		containers := []string{logAssert.Container}
+		if logAssert.Container == "" {
+			containers = containers[:0]
+			for _, container := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+				containers = append(containers, container.Name)
+			}
+		}
+
+		for _, container := range containers {
+			raw, err := kClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container,
+				TailLines: logAssert.TailLines(),
+			}).DoRaw(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get logs of container %s/%s: %w", pod.Name, container, err)
+			}
+			logs := string(raw)
+
+			if logAssert.NotContains != "" && strings.Contains(logs, logAssert.NotContains) {
+				return fmt.Errorf("logs of container %s/%s contain %q", pod.Name, container, logAssert.NotContains)
+			}
+			if notRegex != nil {
+				if loc := notRegex.FindStringIndex(logs); loc != nil {
+					return fmt.Errorf("logs of container %s/%s match regex %q: %q", pod.Name, container, logAssert.NotRegex, logs[loc[0]:loc[1]])
+				}
+			}
+			containsFound = containsFound || (logAssert.Contains != "" && strings.Contains(logs, logAssert.Contains))
+			regexFound = regexFound || (regex != nil && regex.MatchString(logs))
+		}
+	}
+
+	if logAssert.Contains != "" && !containsFound {
+		return fmt.Errorf("logs of %d pod(s) do not contain %q", len(pods), logAssert.Contains)
+	}
+	if regex != nil && !regexFound {
+		return fmt.Errorf("logs of %d pod(s) do not match regex %q", len(pods), logAssert.Regex)
+	}
+	return nil
+}
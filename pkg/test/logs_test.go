@@ -0,0 +1,79 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	kfake "k8s.io/client-go/kubernetes/fake"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+func TestCheckAssertLogs(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: testNamespace, Labels: map[string]string{"app": "hello"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}}},
+	}
+
+	// the fake clientset returns "fake logs" for every container
+	for _, tt := range []struct {
+		name      string
+		logAssert harness.TestLogAssert
+		err       string
+	}{
+		{
+			name:      "contains",
+			logAssert: harness.TestLogAssert{Pod: "hello", Contains: "fake"},
+		},
+		{
+			name:      "does not contain",
+			logAssert: harness.TestLogAssert{Pod: "hello", Contains: "leader elected"},
+			err:       `log assertion [pod==hello]: logs of 1 pod(s) do not contain "leader elected"`,
+		},
+		{
+			name:      "regex with selector",
+			logAssert: harness.TestLogAssert{Selector: "app=hello", Container: "main", Regex: "^fake l.gs$"},
+		},
+		{
+			name:      "forbidden string",
+			logAssert: harness.TestLogAssert{Pod: "hello", NotContains: "logs"},
+			err:       `log assertion [pod==hello]: logs of container hello/main contain "logs"`,
+		},
+		{
+			name:      "forbidden regex",
+			logAssert: harness.TestLogAssert{Pod: "hello", NotRegex: "f[a-z]+"},
+			err:       `log assertion [pod==hello]: logs of container hello/main match regex "f[a-z]+": "fake"`,
+		},
+		{
+			name:      "no pods selected",
+			logAssert: harness.TestLogAssert{Selector: "app=other", Contains: "fake"},
+			err:       `log assertion [label: app=other]: no pods matched selector "app=other" in namespace world`,
+		},
+		{
+			name:      "invalid regex",
+			logAssert: harness.TestLogAssert{Pod: "hello", Regex: "fake("},
+			err:       "invalid log assertion 0: log assertion has invalid regex \"fake(\": error parsing regexp: missing closing ): `fake(`",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			step := Step{
+				Assert:           &harness.TestAssert{Logs: []harness.TestLogAssert{tt.logAssert}},
+				KubernetesClient: func() (kubernetes.Interface, error) { return kfake.NewSimpleClientset(pod), nil },
+				Logger:           testutils.NewTestLogger(t, ""),
+			}
+
+			errs := step.CheckAssertLogs(testNamespace)
+			if tt.err == "" {
+				assert.Empty(t, errs)
+			} else {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
+)
+
+// loadMatrix loads the TestMatrix of the test case in dir. It returns nil if the test case has no matrix file.
+func loadMatrix(dir string) (*harness.TestMatrix, error) {
+	file := filepath.Join(dir, harness.MatrixFile)
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	objects, err := testutils.LoadYAMLFromFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", file, err)
+	}
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("%s must contain exactly one TestMatrix", file)
+	}
+	matrix, ok := objects[0].(*harness.TestMatrix)
+	if !ok {
+		return nil, fmt.Errorf("%s must contain a TestMatrix, found %s", file, objects[0].GetObjectKind().GroupVersionKind().Kind)
+	}
+	if err := matrix.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid matrix in %s: %w", file, err)
+	}
+	return matrix, nil
+}
+
+// nonDNSLabelChars matches the characters which are not allowed in DNS labels, after converting to lower case.
+var nonDNSLabelChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// dnsLabel converts s into a DNS label, e.g. "quay.io/zookeeper:3.9" into "quay-io-zookeeper-3-9".
+func dnsLabel(s string) string {
+	label := strings.Trim(nonDNSLabelChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(label) > validation.DNS1123LabelMaxLength {
+		label = strings.TrimRight(label[:validation.DNS1123LabelMaxLength], "-")
+	}
+	return label
+}
+
+// expandMatrix returns a copy of test for every combination of the matrix. The name of each copy is suffixed with
+// the names and values of its combination converted into DNS labels, e.g. "zookeeper_version-3-9_tls-true", and its
+// variables are those of test and of its combination. It fails if two combinations get the same name.
+func expandMatrix(test *Case, matrix *harness.TestMatrix) ([]*Case, error) {
+	tests := []*Case{}
+	names := map[string]bool{}
+	for _, combination := range matrix.Combinations() {
+		name := test.Name
+		for _, dimension := range matrix.Dimensions {
+			name += fmt.Sprintf("_%s-%s", dnsLabel(dimension.Name), dnsLabel(combination[dimension.Name]))
+		}
+		if names[name] {
+			return nil, fmt.Errorf("matrix of test %s has more than one combination named %s", test.Name, name)
+		}
+		names[name] = true
+
+		variables := make(map[string]string, len(test.Variables)+len(combination))
+		for key, value := range test.Variables {
+			variables[key] = value
+		}
+		for key, value := range combination {
+			variables[key] = value
+		}
+
+		matrixTest := *test
+		matrixTest.Name = name
+		matrixTest.Variables = variables
+		tests = append(tests, &matrixTest)
+	}
+	return tests, nil
+}
@@ -2,8 +2,10 @@ package test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -17,12 +19,16 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
 	"github.com/stackabletech/kuttl/pkg/env"
+	"github.com/stackabletech/kuttl/pkg/expressions"
 	kfile "github.com/stackabletech/kuttl/pkg/file"
 	"github.com/stackabletech/kuttl/pkg/http"
 	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
@@ -49,7 +55,14 @@ type Step struct {
 	Apply   []client.Object
 	Errors  []client.Object
 
+	// Programs are the compiled CEL expressions of Assert, keyed by expression.
+	Programs map[string]*expressions.Program
+
 	Timeout int
+	// SemanticComparison compares resource quantities and timestamps in asserts and errors by value.
+	SemanticComparison bool
+	// ApplyOptions configure how the objects in Apply are created and updated.
+	ApplyOptions testutils.ApplyOptions
 
 	Kubeconfig        string
 	KubeconfigLoading string
@@ -57,6 +70,34 @@ type Step struct {
 
 	Client          func(forceNew bool) (client.Client, error)
 	DiscoveryClient func() (discovery.DiscoveryInterface, error)
+	// KubernetesClient is used for requests which the controller-runtime client does not support, e.g. pod logs.
+	KubernetesClient func() (kubernetes.Interface, error)
+
+	// Variables are passed to the commands of the step, which store the variables of their outputs in it. They are
+	// shared by the steps of a test case.
+	Variables map[string]string
+	// TestInfo is the metadata of the test case, which is exported to the commands of the step together with the
+	// index and name of the step.
+	TestInfo *testutils.TestInfo
+	// ExpandVariables expands $NAMESPACE and Variables in the files of the step, see Expand.
+	ExpandVariables bool
+	// files are the files loaded by LoadYAML.
+	files []string
+	// expansion are the variables expanded in the files while Expand loads them again.
+	expansion map[string]string
+
+	// eventNotes are the compiled note regexes of the event assertions of Assert, by index.
+	eventNotes []*regexp.Regexp
+	// logRegexes are the compiled regexes of the log assertions of Assert, by index.
+	logRegexes []logRegexes
+	// httpRequests are the parsed paths and compiled body regexes of the HTTP assertions of Assert, by index.
+	httpRequests []httpRequest
+	// jsonPaths are the parsed paths of the JSONPath assertions of Assert, by index.
+	jsonPaths []*jsonpath.JSONPath
+
+	// WatchCache, if set, serves the reads of the step's checks and triggers their re-evaluation when
+	// the objects they reference change. It must be for the same cluster as Client.
+	WatchCache *testutils.WatchCache
 
 	Logger testutils.Logger
 }
@@ -87,6 +128,51 @@ func (s *Step) Clean(namespace string) error {
 	return nil
 }
 
+// resolveReference returns the object ref refers to by name, or all objects of its kind matching its labels.
+// Unless ref sets a namespace, namespaced objects are looked up in namespace.
+func resolveReference(cl client.Client, dClient discovery.DiscoveryInterface, ref harness.ObjectReference, namespace string) ([]client.Object, error) {
+	gvk := ref.GroupVersionKind()
+
+	obj := testutils.NewResource(gvk.GroupVersion().String(), gvk.Kind, ref.Name, "")
+
+	objNs := namespace
+	if ref.Namespace != "" {
+		objNs = ref.Namespace
+	}
+
+	_, objNs, err := testutils.Namespaced(dClient, obj, objNs)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.Name != "" {
+		return []client.Object{obj.DeepCopy()}, nil
+	}
+
+	u := &unstructured.UnstructuredList{}
+	u.SetGroupVersionKind(gvk)
+
+	listOptions := []client.ListOption{}
+
+	if ref.Labels != nil {
+		listOptions = append(listOptions, client.MatchingLabels(ref.Labels))
+	}
+
+	if objNs != "" {
+		listOptions = append(listOptions, client.InNamespace(objNs))
+	}
+
+	if err := cl.List(context.TODO(), u, listOptions...); err != nil {
+		return nil, fmt.Errorf("listing matching resources: %w", err)
+	}
+
+	objs := make([]client.Object, 0, len(u.Items))
+	for index := range u.Items {
+		objs = append(objs, &u.Items[index])
+	}
+	return objs, nil
+}
+
 // DeleteExisting deletes any resources in the TestStep.Delete list prior to running the tests.
 func (s *Step) DeleteExisting(namespace string) error {
 	cl, err := s.Client(false)
@@ -106,46 +192,11 @@ func (s *Step) DeleteExisting(namespace string) error {
 	}
 
 	for _, ref := range s.Step.Delete {
-		gvk := ref.GroupVersionKind()
-
-		obj := testutils.NewResource(gvk.GroupVersion().String(), gvk.Kind, ref.Name, "")
-
-		objNs := namespace
-		if ref.Namespace != "" {
-			objNs = ref.Namespace
-		}
-
-		_, objNs, err := testutils.Namespaced(dClient, obj, objNs)
+		objs, err := resolveReference(cl, dClient, ref, namespace)
 		if err != nil {
 			return err
 		}
-
-		if ref.Name == "" {
-			u := &unstructured.UnstructuredList{}
-			u.SetGroupVersionKind(gvk)
-
-			listOptions := []client.ListOption{}
-
-			if ref.Labels != nil {
-				listOptions = append(listOptions, client.MatchingLabels(ref.Labels))
-			}
-
-			if objNs != "" {
-				listOptions = append(listOptions, client.InNamespace(objNs))
-			}
-
-			err := cl.List(context.TODO(), u, listOptions...)
-			if err != nil {
-				return fmt.Errorf("listing matching resources: %w", err)
-			}
-
-			for index := range u.Items {
-				toDelete = append(toDelete, &u.Items[index])
-			}
-		} else {
-			// Otherwise just append the object specified.
-			toDelete = append(toDelete, obj.DeepCopy())
-		}
+		toDelete = append(toDelete, objs...)
 	}
 
 	for _, obj := range toDelete {
@@ -175,6 +226,51 @@ func (s *Step) DeleteExisting(namespace string) error {
 	})
 }
 
+// Patch applies the patches in the TestStep.Patch list. The patched objects are resolved like those of
+// TestStep.Delete, but a patch which matches no objects is an error.
+func (s *Step) Patch(namespace string) []error {
+	if s.Step == nil || len(s.Step.Patch) == 0 {
+		return nil
+	}
+
+	cl, err := s.Client(false)
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	errors := []error{}
+
+	for i := range s.Step.Patch {
+		patch := &s.Step.Patch[i]
+
+		objs, err := resolveReference(cl, dClient, patch.ObjectReference, namespace)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("patch %s: %w", patch.String(), err))
+			continue
+		}
+		if len(objs) == 0 {
+			errors = append(errors, fmt.Errorf("patch %s: no resources matched", patch.String()))
+			continue
+		}
+
+		patchType, data := patch.Data()
+		for _, obj := range objs {
+			if err := cl.Patch(context.TODO(), obj, client.RawPatch(patchType, data)); err != nil {
+				errors = append(errors, fmt.Errorf("patch %s: %w", testutils.ResourceID(obj), err))
+				continue
+			}
+			s.Logger.Log(testutils.ResourceID(obj), "patched")
+		}
+	}
+
+	return errors
+}
+
 // Create applies all resources defined in the Apply list.
 func (s *Step) Create(test *testing.T, namespace string) []error {
 	cl, err := s.Client(true)
@@ -195,6 +291,24 @@ func (s *Step) Create(test *testing.T, namespace string) []error {
 			errors = append(errors, err)
 			continue
 		}
+
+		rejection, err := testutils.ExpectedRejectionFromAnnotations(obj.GetAnnotations())
+		if err != nil {
+			errors = append(errors, fmt.Errorf("resource %s: %w", testutils.ResourceID(obj), err))
+			continue
+		}
+		// the annotations which configure kuttl are not sent to the API server
+		obj = testutils.WithoutKuttlAnnotations(obj)
+
+		// the status is dropped by the API server when the object is created or updated
+		var status map[string]interface{}
+		if s.Step != nil && s.Step.ApplyStatus && rejection == nil {
+			if status, err = statusOf(obj); err != nil {
+				errors = append(errors, fmt.Errorf("resource %s: %w", testutils.ResourceID(obj), err))
+				continue
+			}
+		}
+
 		ctx := context.Background()
 		if s.Timeout > 0 {
 			var cancel context.CancelFunc
@@ -202,29 +316,83 @@ func (s *Step) Create(test *testing.T, namespace string) []error {
 			defer cancel()
 		}
 
-		if updated, err := testutils.CreateOrUpdate(ctx, cl, obj, true); err != nil {
-			errors = append(errors, err)
+		var updated bool
+		if rejection != nil {
+			// a single attempt, so that the rejection is not retried and timeouts are reported as they are
+			updated, err = testutils.CreateOrUpdateOnce(ctx, cl, obj, s.ApplyOptions)
 		} else {
-			// if the object was created, register cleanup
-			if !updated && !s.SkipDelete {
-				obj := obj
-				test.Cleanup(func() {
-					if err := cl.Delete(context.TODO(), obj); err != nil && !k8serrors.IsNotFound(err) {
-						test.Error(err)
-					}
-				})
+			updated, err = testutils.CreateOrUpdateWithOptions(ctx, cl, obj, true, s.ApplyOptions)
+		}
+		if rejection != nil {
+			if rejectionErr := rejection.Check(err); rejectionErr != nil {
+				errors = append(errors, fmt.Errorf("resource %s: %w", testutils.ResourceID(obj), rejectionErr))
+			} else {
+				s.Logger.Log(testutils.ResourceID(obj), "rejected as expected:", err)
+			}
+			// an accepted object still has to be cleaned up
+			if err != nil {
+				continue
 			}
-			action := "created"
-			if updated {
-				action = "updated"
+		} else if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
+		// if the object was created, register cleanup
+		if !updated && !s.SkipDelete {
+			obj := obj
+			test.Cleanup(func() {
+				if err := cl.Delete(context.TODO(), obj); err != nil && !k8serrors.IsNotFound(err) {
+					test.Error(err)
+				}
+			})
+		}
+		action := "created"
+		if updated {
+			action = "updated"
+		}
+		s.Logger.Log(testutils.ResourceID(obj), action)
+
+		if status != nil {
+			if err := writeStatus(ctx, cl, obj, status); err != nil {
+				errors = append(errors, fmt.Errorf("resource %s: failed to write status: %w", testutils.ResourceID(obj), err))
+				continue
 			}
-			s.Logger.Log(testutils.ResourceID(obj), action)
+			s.Logger.Log(testutils.ResourceID(obj), "status written")
 		}
 	}
 
 	return errors
 }
 
+// statusOf returns the status of obj, or nil if it has none.
+func statusOf(obj client.Object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	status, ok := content["status"].(map[string]interface{})
+	if !ok || len(status) == 0 {
+		return nil, nil
+	}
+	return status, nil
+}
+
+// writeStatus merge patches the status subresource of obj with status.
+func writeStatus(ctx context.Context, cl client.Client, obj client.Object, status map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	target.SetName(obj.GetName())
+	target.SetNamespace(obj.GetNamespace())
+
+	return cl.Status().Patch(ctx, target, client.RawPatch(types.MergePatchType, patch))
+}
+
 // GetTimeout gets the timeout defined for the test step.
 func (s *Step) GetTimeout() int {
 	timeout := s.Timeout
@@ -254,9 +422,50 @@ func list(cl client.Client, gvk schema.GroupVersionKind, namespace string, label
 	return list.Items, nil
 }
 
+// subsetOptions returns the options for comparing an expected object with the given kuttl.dev/ annotations.
+func (s *Step) subsetOptions(kuttlAnnotations map[string]string) (testutils.SubsetOptions, error) {
+	opts, err := testutils.SubsetOptionsFromAnnotations(kuttlAnnotations)
+	opts.SemanticComparison = opts.SemanticComparison || s.SemanticComparison
+	return opts, err
+}
+
+// checkClient returns the client used to check asserts, which reads from the watch cache if the step has one.
+func (s *Step) checkClient() (client.Client, error) {
+	cl, err := s.Client(false)
+	if err != nil || s.WatchCache == nil {
+		return cl, err
+	}
+	return s.WatchCache.Client(cl), nil
+}
+
+// watchTargets returns the kinds and namespaces of the objects referenced by the step's asserts and errors.
+func (s *Step) watchTargets(namespace string) []testutils.WatchTarget {
+	targets := []testutils.WatchTarget{}
+	add := func(gvk schema.GroupVersionKind, objNs string) {
+		if objNs == "" {
+			objNs = namespace
+		}
+		targets = append(targets, testutils.WatchTarget{GVK: gvk, Namespace: objNs})
+	}
+
+	for _, obj := range append(append([]client.Object{}, s.Asserts...), s.Errors...) {
+		add(obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace())
+	}
+	if s.Assert != nil {
+		for _, ref := range s.Assert.ResourceRefs {
+			add(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind), ref.Namespace)
+		}
+		for _, jsonPathAssert := range s.Assert.JSONPath {
+			add(schema.FromAPIVersionAndKind(jsonPathAssert.APIVersion, jsonPathAssert.Kind), jsonPathAssert.Namespace)
+		}
+	}
+
+	return targets
+}
+
 // CheckResource checks if the expected resource's state in Kubernetes is correct.
 func (s *Step) CheckResource(expected runtime.Object, namespace string) []error {
-	cl, err := s.Client(false)
+	cl, err := s.checkClient()
 	if err != nil {
 		return []error{err}
 	}
@@ -273,6 +482,23 @@ func (s *Step) CheckResource(expected runtime.Object, namespace string) []error
 		return append(testErrors, err)
 	}
 
+	expectedObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(expected)
+	if err != nil {
+		return append(testErrors, err)
+	}
+	expectedObj, kuttlAnnotations := testutils.SplitKuttlAnnotations(expectedObj)
+	subsetOpts, err := s.subsetOptions(kuttlAnnotations)
+	if err != nil {
+		return append(testErrors, fmt.Errorf("resource %s: %w", testutils.ResourceID(expected), err))
+	}
+	count, err := testutils.CountConstraintFromAnnotations(kuttlAnnotations)
+	if err != nil {
+		return append(testErrors, fmt.Errorf("resource %s: %w", testutils.ResourceID(expected), err))
+	}
+	if count != nil && name != "" {
+		return append(testErrors, fmt.Errorf("resource %s: count constraints can only be used on resources without a name", testutils.ResourceID(expected)))
+	}
+
 	gvk := expected.GetObjectKind().GroupVersionKind()
 
 	actuals := []unstructured.Unstructured{}
@@ -297,25 +523,34 @@ func (s *Step) CheckResource(expected runtime.Object, namespace string) []error
 		if err != nil {
 			return append(testErrors, err)
 		}
-		if len(matches) == 0 {
+		if len(matches) == 0 && count == nil {
 			testErrors = append(testErrors, fmt.Errorf("no resources matched of kind: %s", gvk.String()))
 		}
 		actuals = append(actuals, matches...)
 	}
-	expectedObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(expected)
-	if err != nil {
-		return append(testErrors, err)
+
+	if count != nil {
+		matching := 0
+		for _, actual := range actuals {
+			if testutils.IsSubsetWithOptions(expectedObj, actual.UnstructuredContent(), subsetOpts) == nil {
+				matching++
+			}
+		}
+		if err := count.Check(matching); err != nil {
+			return append(testErrors, fmt.Errorf("resource %s: %w (%d of kind %s listed)", testutils.ResourceID(expected), err, len(actuals), gvk.String()))
+		}
+		return testErrors
 	}
 
 	for _, actual := range actuals {
 		actual := actual
 		tmpTestErrors := []error{}
 
-		if err := testutils.IsSubset(expectedObj, actual.UnstructuredContent()); err != nil {
+		if err := testutils.IsSubsetWithOptions(expectedObj, actual.UnstructuredContent(), subsetOpts); err != nil {
 			diff, diffErr := testutils.PrettyDiff(
 				&unstructured.Unstructured{Object: expectedObj}, &actual)
 			if diffErr == nil {
-				tmpTestErrors = append(tmpTestErrors, fmt.Errorf(diff))
+				tmpTestErrors = append(tmpTestErrors, &diffError{diff: diff})
 			} else {
 				tmpTestErrors = append(tmpTestErrors, diffErr)
 			}
@@ -333,7 +568,8 @@ func (s *Step) CheckResource(expected runtime.Object, namespace string) []error
 	return testErrors
 }
 
-// CheckResourceAbsent checks if the expected resource's state is absent in Kubernetes.
+// CheckResourceAbsent checks if the expected resource's state is absent in Kubernetes. It always reads from the API
+// server, as a lagging watch cache could report an object as absent which has just been created.
 func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string) error {
 	cl, err := s.Client(false)
 	if err != nil {
@@ -385,10 +621,22 @@ func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string) er
 	if err != nil {
 		return err
 	}
+	expectedObj, kuttlAnnotations := testutils.SplitKuttlAnnotations(expectedObj)
+	subsetOpts, err := s.subsetOptions(kuttlAnnotations)
+	if err != nil {
+		return fmt.Errorf("resource %s: %w", testutils.ResourceID(expected), err)
+	}
+	count, err := testutils.CountConstraintFromAnnotations(kuttlAnnotations)
+	if err != nil {
+		return fmt.Errorf("resource %s: %w", testutils.ResourceID(expected), err)
+	}
+	if count != nil {
+		return fmt.Errorf("resource %s: count constraints can not be used in errors files", testutils.ResourceID(expected))
+	}
 
 	var unexpectedObjects []unstructured.Unstructured
 	for _, actual := range actuals {
-		if err := testutils.IsSubset(expectedObj, actual.UnstructuredContent()); err == nil {
+		if err := testutils.IsSubsetWithOptions(expectedObj, actual.UnstructuredContent(), subsetOpts); err == nil {
 			unexpectedObjects = append(unexpectedObjects, actual)
 		}
 	}
@@ -396,22 +644,117 @@ func (s *Step) CheckResourceAbsent(expected runtime.Object, namespace string) er
 	if len(unexpectedObjects) == 0 {
 		return nil
 	}
+
+	forbidden := &forbiddenStateError{}
 	if len(unexpectedObjects) == 1 {
-		return fmt.Errorf("resource %s %s matched error assertion", unexpectedObjects[0].GroupVersionKind(), unexpectedObjects[0].GetName())
+		forbidden.message = fmt.Sprintf("resource %s %s matched error assertion", unexpectedObjects[0].GroupVersionKind(), unexpectedObjects[0].GetName())
+	} else {
+		forbidden.message = fmt.Sprintf("resource %s %s (and %d other resources) matched error assertion", unexpectedObjects[0].GroupVersionKind(), unexpectedObjects[0].GetName(), len(unexpectedObjects)-1)
+	}
+	forbidden.diff, forbidden.diffErr = testutils.PrettyDiff(&unstructured.Unstructured{Object: expectedObj}, &unexpectedObjects[0])
+	return forbidden
+}
+
+// forbiddenStateError is returned by CheckResourceAbsent if an object matching an errors object exists.
+type forbiddenStateError struct {
+	message string
+	// diff is the diff between the errors object and the first matching object.
+	diff    string
+	diffErr error
+}
+
+func (e *forbiddenStateError) Error() string {
+	return e.message
+}
+
+// diffError is the diff between an expected and an actual object. It is logged along with the error describing the
+// mismatch, but left out of the JUnit report, as it can be very long.
+type diffError struct {
+	diff string
+}
+
+func (e *diffError) Error() string {
+	return e.diff
+}
+
+// withoutDiffs returns the errors which are not diffs.
+func withoutDiffs(errs []error) []error {
+	filtered := []error{}
+	for _, err := range errs {
+		var diff *diffError
+		if !errors.As(err, &diff) {
+			filtered = append(filtered, err)
+		}
 	}
-	return fmt.Errorf("resource %s %s (and %d other resources) matched error assertion", unexpectedObjects[0].GroupVersionKind(), unexpectedObjects[0].GetName(), len(unexpectedObjects)-1)
+	return filtered
+}
+
+// forbiddenStates returns the failures to report for the forbidden states found in testErrors, including the diffs
+// of the offending objects. It returns nil if testErrors contains no forbidden state.
+func forbiddenStates(testErrors []error) []error {
+	var failures []error
+	for _, err := range testErrors {
+		var forbidden *forbiddenStateError
+		if !errors.As(err, &forbidden) {
+			continue
+		}
+		if forbidden.diffErr == nil {
+			failures = append(failures, &diffError{diff: forbidden.diff})
+		} else {
+			failures = append(failures, forbidden.diffErr)
+		}
+		failures = append(failures, fmt.Errorf("forbidden state observed: %w", forbidden))
+	}
+	return failures
 }
 
 // CheckAssertCommands Runs the commands provided in `commands` and check if have been run successfully.
 // the errors returned can be a a failure of executing the command or the failure of the command executed.
 func (s *Step) CheckAssertCommands(ctx context.Context, namespace string, commands []harness.TestAssertCommand, timeout int) []error {
 	testErrors := []error{}
-	if _, err := testutils.RunAssertCommands(ctx, s.Logger, namespace, commands, s.Dir, timeout, s.Kubeconfig); err != nil {
+	if _, err := testutils.RunAssertCommandsWithOptions(ctx, s.Logger, namespace, commands, s.Dir, timeout, s.Kubeconfig, s.commandOptions()); err != nil {
 		testErrors = append(testErrors, err)
 	}
 	return testErrors
 }
 
+// CheckAssertExpressions fetches the resources referenced by the TestAssert and evaluates its
+// assertAny and assertAll CEL expressions against them.
+func (s *Step) CheckAssertExpressions(namespace string) []error {
+	if s.Assert == nil || (len(s.Assert.AssertAny) == 0 && len(s.Assert.AssertAll) == 0) {
+		return nil
+	}
+
+	cl, err := s.checkClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	dClient, err := s.DiscoveryClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	variables := map[string]interface{}{}
+
+	for _, ref := range s.Assert.ResourceRefs {
+		obj := testutils.NewResource(ref.APIVersion, ref.Kind, ref.Name, ref.Namespace)
+
+		name, objNs, err := testutils.Namespaced(dClient, obj, namespace)
+		if err != nil {
+			return []error{fmt.Errorf("resource ref %q: %w", ref.Ref, err)}
+		}
+
+		if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: objNs, Name: name}, obj); err != nil {
+			return []error{fmt.Errorf("resource ref %q: %w", ref.Ref, err)}
+		}
+
+		variables[ref.Ref] = obj.Object
+	}
+
+	return expressions.RunAssertExpressions(s.Programs, variables, s.Assert.AssertAny, s.Assert.AssertAll)
+}
+
 // Check checks if the resources defined in Asserts and Errors are in the correct state.
 func (s *Step) Check(namespace string, timeout int) []error {
 	testErrors := []error{}
@@ -422,6 +765,11 @@ func (s *Step) Check(namespace string, timeout int) []error {
 
 	if s.Assert != nil {
 		testErrors = append(testErrors, s.CheckAssertCommands(context.TODO(), namespace, s.Assert.Commands, timeout)...)
+		testErrors = append(testErrors, s.CheckAssertExpressions(namespace)...)
+		testErrors = append(testErrors, s.CheckAssertJSONPaths(namespace)...)
+		testErrors = append(testErrors, s.CheckAssertHTTP(namespace, timeout)...)
+		testErrors = append(testErrors, s.CheckAssertLogs(namespace)...)
+		testErrors = append(testErrors, s.CheckAssertEvents(namespace)...)
 	}
 
 	for _, expected := range s.Errors {
@@ -433,8 +781,54 @@ func (s *Step) Check(namespace string, timeout int) []error {
 	return testErrors
 }
 
+// minCheckInterval is the minimum interval between two checks of a step which are triggered by changes, so that a
+// burst of changes, e.g. of a controller updating a status in a tight loop, does not result in back-to-back checks.
+const minCheckInterval = 250 * time.Millisecond
+
+// waitForChange waits until changes delivers a notification, but not sooner than minCheckInterval after the last
+// check, or until wait has passed.
+func waitForChange(changes <-chan struct{}, lastCheck time.Time, wait time.Duration) {
+	deadline := time.Now().Add(wait)
+	select {
+	case <-changes:
+		next := lastCheck.Add(minCheckInterval)
+		if next.After(deadline) {
+			next = deadline
+		}
+		time.Sleep(time.Until(next))
+	case <-time.After(wait):
+	}
+}
+
+// CheckConsistently checks that the asserted state keeps holding for the given duration, re-checking whenever
+// changes delivers a notification, at most every minCheckInterval, and at least once per second. It returns the
+// errors of the first failed check.
+func (s *Step) CheckConsistently(namespace string, duration time.Duration, changes <-chan struct{}) []error {
+	start := time.Now()
+	deadline := start.Add(duration)
+
+	for {
+		lastCheck := time.Now()
+		remaining := deadline.Sub(lastCheck)
+		if testErrors := s.Check(namespace, int(math.Max(1, math.Ceil(remaining.Seconds())))); len(testErrors) != 0 {
+			err := fmt.Errorf("asserted state did not hold for %s, it broke after %s", duration, time.Since(start).Round(time.Millisecond))
+			return append([]error{err}, testErrors...)
+		}
+
+		if remaining <= 0 {
+			return []error{}
+		}
+
+		wait := time.Second
+		if remaining < wait {
+			wait = remaining
+		}
+		waitForChange(changes, lastCheck, wait)
+	}
+}
+
 // Run runs a KUTTL test step:
-// 1. Apply all desired objects to Kubernetes.
+// 1. Apply all desired objects and patches to Kubernetes.
 // 2. Wait for all of the states defined in the test step's asserts to be true.'
 func (s *Step) Run(test *testing.T, namespace string) []error {
 	s.Logger.Log("starting test step", s.String())
@@ -452,21 +846,40 @@ func (s *Step) Run(test *testing.T, namespace string) []error {
 				command.Background = false
 			}
 		}
-		if _, err := testutils.RunCommands(context.TODO(), s.Logger, namespace, s.Step.Commands, s.Dir, s.Timeout, s.Kubeconfig); err != nil {
+		commands, secrets, err := testutils.ResolveEnv(context.TODO(), s.Client, namespace, s.Dir, s.Step.Env, s.Step.Commands)
+		if err != nil {
+			return []error{err}
+		}
+		logger := testutils.NewMaskingLogger(s.Logger, secrets)
+		if _, err := testutils.RunCommandsWithOptions(context.TODO(), logger, namespace, commands, s.Dir, s.Timeout, s.Kubeconfig, s.commandOptions()); err != nil {
 			testErrors = append(testErrors, err)
 		}
 	}
 
 	testErrors = append(testErrors, s.Create(test, namespace)...)
+	testErrors = append(testErrors, s.Patch(namespace)...)
 
 	if len(testErrors) != 0 {
 		return testErrors
 	}
 
+	// Re-check as soon as a referenced object changes, polling in case a change is not observed.
+	var changes <-chan struct{}
+	if s.WatchCache != nil {
+		subscription := s.WatchCache.Subscribe(s.watchTargets(namespace)...)
+		defer subscription.Close()
+		changes = subscription.C
+	}
+
 	timeoutF := float64(s.GetTimeout())
+	if s.Assert != nil && s.Assert.Consistently > 0 {
+		// the consistently window is part of the timeout, so the state has to be reached before it starts
+		timeoutF -= float64(s.Assert.Consistently)
+	}
 	start := time.Now()
 
 	for elapsed := 0.0; elapsed < timeoutF; elapsed = time.Since(start).Seconds() {
+		lastCheck := time.Now()
 		testErrors = s.Check(namespace, int(timeoutF-elapsed))
 
 		if len(testErrors) == 0 {
@@ -475,7 +888,18 @@ func (s *Step) Run(test *testing.T, namespace string) []error {
 		if hasTimeoutErr(testErrors) {
 			break
 		}
-		time.Sleep(time.Second)
+		if s.Assert != nil && s.Assert.FailFast {
+			if forbidden := forbiddenStates(testErrors); len(forbidden) > 0 {
+				testErrors = forbidden
+				break
+			}
+		}
+		waitForChange(changes, lastCheck, time.Second)
+	}
+
+	if len(testErrors) == 0 && s.Assert != nil && s.Assert.Consistently > 0 {
+		s.Logger.Logf("asserted state reached, checking that it holds for %d seconds", s.Assert.Consistently)
+		testErrors = s.CheckConsistently(namespace, time.Duration(s.Assert.Consistently)*time.Second, changes)
 	}
 
 	// all is good
@@ -494,7 +918,7 @@ func (s *Step) Run(test *testing.T, namespace string) []error {
 			s.Logger.Log("skipping invalid assertion collector")
 			continue
 		}
-		_, err := testutils.RunCommand(context.TODO(), namespace, *collector.Command(), s.Dir, s.Logger, s.Logger, s.Logger, s.Timeout, s.Kubeconfig)
+		_, err := testutils.RunCommandWithOptions(context.TODO(), namespace, *collector.Command(), s.Dir, s.Logger, s.Logger, s.Logger, s.Timeout, s.Kubeconfig, s.commandOptions())
 		if err != nil {
 			s.Logger.Log("post assert collector failure: %s", err)
 		}
@@ -503,11 +927,46 @@ func (s *Step) Run(test *testing.T, namespace string) []error {
 	return testErrors
 }
 
+// commandOptions returns the options of the commands of the step.
+func (s *Step) commandOptions() testutils.CommandOptions {
+	return testutils.CommandOptions{Variables: s.Variables, TestInfo: s.testInfo(), Client: s.Client, DiscoveryClient: s.DiscoveryClient}
+}
+
+// testInfo returns the metadata of the test case and the step, which is exported to the commands of the step.
+func (s *Step) testInfo() *testutils.TestInfo {
+	return s.TestInfo.WithStep(s.Index, s.Name)
+}
+
 // String implements the string interface, returning the name of the test step.
 func (s *Step) String() string {
 	return fmt.Sprintf("%d-%s", s.Index, s.Name)
 }
 
+// Expand loads the files of the step again, expanding $NAMESPACE and the Variables in their contents like in the
+// arguments of commands. Use $$ for a literal $.
+func (s *Step) Expand(namespace string) error {
+	variables := map[string]string{}
+	for key, value := range s.Variables {
+		variables[key] = value
+	}
+	variables["NAMESPACE"] = namespace
+
+	files := s.files
+	s.files = nil
+	s.Step, s.Assert, s.Programs = nil, nil, nil
+	s.Apply, s.Asserts, s.Errors = []client.Object{}, []client.Object{}, []client.Object{}
+
+	s.expansion = variables
+	defer func() { s.expansion = nil }()
+
+	for _, file := range files {
+		if err := s.LoadYAML(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // LoadYAML loads the resources from a YAML file for a test step:
 //   - If the YAML file is called "assert", then it contains objects to
 //     add to the test step's list of assertions.
@@ -515,21 +974,77 @@ func (s *Step) String() string {
 //     if seen, mark a test immediately failed.
 //   - All other YAML files are considered resources to create.
 func (s *Step) LoadYAML(file string) error {
-	skipFile, objects, err := s.loadOrSkipFile(file)
+	s.files = append(s.files, file)
+
+	skipFile, testFile, objects, err := s.loadOrSkipFile(file)
 	if skipFile || err != nil {
 		return err
 	}
 
+	numAsserts, numErrors := len(s.Asserts), len(s.Errors)
+
 	if err = s.populateObjectsByFileName(filepath.Base(file), objects); err != nil {
 		return fmt.Errorf("populating step: %v", err)
 	}
 
+	if testFile != nil && testFile.ListMatching != "" {
+		switch testFile.ListMatching {
+		case harness.ListMatchingStrict, harness.ListMatchingUnordered:
+		default:
+			return fmt.Errorf("attribute 'listMatching' in %s has invalid value %q", file, testFile.ListMatching)
+		}
+		defaultAnnotation(s.Asserts[numAsserts:], harness.ListMatchingAnnotation, testFile.ListMatching)
+		defaultAnnotation(s.Errors[numErrors:], harness.ListMatchingAnnotation, testFile.ListMatching)
+	}
+
 	asserts := []client.Object{}
 
 	for _, obj := range s.Asserts {
 		if obj.GetObjectKind().GroupVersionKind().Kind == "TestAssert" {
 			if testAssert, ok := obj.DeepCopyObject().(*harness.TestAssert); ok {
 				s.Assert = testAssert
+				if timeout := s.GetTimeout(); testAssert.Consistently > 0 && timeout > 0 && testAssert.Consistently >= timeout {
+					return fmt.Errorf("consistently (%d seconds) in %s must be shorter than the timeout of the step (%d seconds)", testAssert.Consistently, file, timeout)
+				}
+				if s.Programs, err = expressions.LoadPrograms(testAssert); err != nil {
+					return fmt.Errorf("failed to load CEL expressions from %s: %w", file, err)
+				}
+				s.jsonPaths = nil
+				if len(testAssert.JSONPath) > 0 {
+					s.jsonPaths = make([]*jsonpath.JSONPath, len(testAssert.JSONPath))
+				}
+				for i := range testAssert.JSONPath {
+					if s.jsonPaths[i], err = testAssert.JSONPath[i].Compile(); err != nil {
+						return fmt.Errorf("invalid jsonpath assertion %d in %s: %w", i, file, err)
+					}
+				}
+				s.httpRequests = nil
+				if len(testAssert.HTTP) > 0 {
+					s.httpRequests = make([]httpRequest, len(testAssert.HTTP))
+				}
+				for i := range testAssert.HTTP {
+					if s.httpRequests[i].path, s.httpRequests[i].bodyRegex, err = testAssert.HTTP[i].Compile(); err != nil {
+						return fmt.Errorf("invalid http assertion %d in %s: %w", i, file, err)
+					}
+				}
+				s.logRegexes = nil
+				if len(testAssert.Logs) > 0 {
+					s.logRegexes = make([]logRegexes, len(testAssert.Logs))
+				}
+				for i := range testAssert.Logs {
+					if s.logRegexes[i].regex, s.logRegexes[i].notRegex, err = testAssert.Logs[i].Compile(); err != nil {
+						return fmt.Errorf("invalid log assertion %d in %s: %w", i, file, err)
+					}
+				}
+				s.eventNotes = nil
+				if len(testAssert.Events) > 0 {
+					s.eventNotes = make([]*regexp.Regexp, len(testAssert.Events))
+				}
+				for i := range testAssert.Events {
+					if s.eventNotes[i], err = testAssert.Events[i].Compile(); err != nil {
+						return fmt.Errorf("invalid event assertion %d in %s: %w", i, file, err)
+					}
+				}
 			} else {
 				return fmt.Errorf("failed to load TestAssert object from %s: it contains an object of type %T", file, obj)
 			}
@@ -566,6 +1081,37 @@ func (s *Step) LoadYAML(file string) error {
 			default:
 				return fmt.Errorf("attribute 'kubeconfigLoading' has invalid value %q", s.Step.KubeconfigLoading)
 			}
+			s.ExpandVariables = s.ExpandVariables || s.Step.ExpandVariables
+			if s.Step.UnitTest && s.Step.Kubeconfig != "" {
+				return fmt.Errorf("attribute 'kubeconfig' can not be set for the unit test step in %s", file)
+			}
+
+			for i := range s.Step.Commands {
+				if err := s.Step.Commands[i].ValidateOutput(); err != nil {
+					return fmt.Errorf("invalid command %d in %s: %w", i, file, err)
+				}
+				if err := harness.ValidateEnv(s.Step.Commands[i].Env); err != nil {
+					return fmt.Errorf("invalid command %d in %s: %w", i, file, err)
+				}
+			}
+			if err := harness.ValidateEnv(s.Step.Env); err != nil {
+				return fmt.Errorf("invalid env in %s: %w", file, err)
+			}
+
+			for i := range s.Step.Patch {
+				if err := s.Step.Patch[i].Validate(); err != nil {
+					return fmt.Errorf("invalid patch %d in %s: %w", i, file, err)
+				}
+			}
+
+			s.ApplyOptions = s.ApplyOptions.Merge(testutils.ApplyOptions{
+				Strategy:       s.Step.ApplyStrategy,
+				FieldManager:   s.Step.FieldManager,
+				ForceConflicts: s.Step.ForceConflicts,
+			})
+			if err := s.ApplyOptions.Validate(); err != nil {
+				return fmt.Errorf("invalid apply options in %s: %w", file, err)
+			}
 		} else {
 			applies = append(applies, obj)
 		}
@@ -576,7 +1122,7 @@ func (s *Step) LoadYAML(file string) error {
 		// process configured step applies
 		for _, applyPath := range s.Step.Apply {
 			exApply := env.Expand(applyPath)
-			apply, err := ObjectsFromPath(exApply, s.Dir)
+			apply, err := ObjectsFromPathWithVariables(exApply, s.Dir, s.expansion)
 			if err != nil {
 				return fmt.Errorf("step %q apply path %s: %w", s.Name, exApply, err)
 			}
@@ -585,7 +1131,7 @@ func (s *Step) LoadYAML(file string) error {
 		// process configured step asserts
 		for _, assertPath := range s.Step.Assert {
 			exAssert := env.Expand(assertPath)
-			assert, err := ObjectsFromPath(exAssert, s.Dir)
+			assert, err := ObjectsFromPathWithVariables(exAssert, s.Dir, s.expansion)
 			if err != nil {
 				return fmt.Errorf("step %q assert path %s: %w", s.Name, exAssert, err)
 			}
@@ -594,7 +1140,7 @@ func (s *Step) LoadYAML(file string) error {
 		// process configured errors
 		for _, errorPath := range s.Step.Error {
 			exError := env.Expand(errorPath)
-			errObjs, err := ObjectsFromPath(exError, s.Dir)
+			errObjs, err := ObjectsFromPathWithVariables(exError, s.Dir, s.expansion)
 			if err != nil {
 				return fmt.Errorf("step %q error path %s: %w", s.Name, exError, err)
 			}
@@ -607,25 +1153,30 @@ func (s *Step) LoadYAML(file string) error {
 	return nil
 }
 
-func (s *Step) loadOrSkipFile(file string) (bool, []client.Object, error) {
-	loadedObjects, err := testutils.LoadYAMLFromFile(file)
+func (s *Step) loadOrSkipFile(file string) (bool, *harness.TestFile, []client.Object, error) {
+	loadedObjects, err := testutils.LoadExpandedYAMLFromFile(file, s.expansion)
 	if err != nil {
-		return false, nil, fmt.Errorf("loading %s: %s", file, err)
+		return false, nil, nil, fmt.Errorf("loading %s: %s", file, err)
 	}
 
 	var objects []client.Object
+	var testFile *harness.TestFile
 	shouldSkip := false
-	testFileObjEncountered := false
 
 	for i, object := range loadedObjects {
 		if testFileObject, ok := object.(*harness.TestFile); ok {
-			if testFileObjEncountered {
-				return false, nil, fmt.Errorf("more than one TestFile object encountered in file %q", file)
+			if testFile != nil {
+				return false, nil, nil, fmt.Errorf("more than one TestFile object encountered in file %q", file)
+			}
+			testFile = testFileObject
+			if testFileObject.TestRunSelector == nil {
+				// a TestFile without a selector, e.g. one which only configures list matching, is included in
+				// every test run like one with an empty selector
+				continue
 			}
-			testFileObjEncountered = true
 			selector, err := metav1.LabelSelectorAsSelector(testFileObject.TestRunSelector)
 			if err != nil {
-				return false, nil, fmt.Errorf("unrecognized test run selector in object %d of %q: %w", i, file, err)
+				return false, nil, nil, fmt.Errorf("unrecognized test run selector in object %d of %q: %w", i, file, err)
 			}
 			if selector.Empty() || selector.Matches(s.TestRunLabels) {
 				continue
@@ -636,7 +1187,22 @@ func (s *Step) loadOrSkipFile(file string) (bool, []client.Object, error) {
 			objects = append(objects, object)
 		}
 	}
-	return shouldSkip, objects, nil
+	return shouldSkip, testFile, objects, nil
+}
+
+// defaultAnnotation sets the annotation key to value on all objects which do not set it themselves.
+func defaultAnnotation(objects []client.Object, key, value string) {
+	for _, obj := range objects {
+		annotations := obj.GetAnnotations()
+		if _, ok := annotations[key]; ok {
+			continue
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[key] = value
+		obj.SetAnnotations(annotations)
+	}
 }
 
 // populateObjectsByFileName populates s.Asserts, s.Errors, and/or s.Apply for files containing
@@ -669,8 +1235,14 @@ func (s *Step) populateObjectsByFileName(fileName string, objects []client.Objec
 
 // ObjectsFromPath returns an array of runtime.Objects for files / urls provided
 func ObjectsFromPath(path, dir string) ([]client.Object, error) {
+	return ObjectsFromPathWithVariables(path, dir, nil)
+}
+
+// ObjectsFromPathWithVariables is like ObjectsFromPath, but expands the variables in the files like
+// testutils.LoadExpandedYAML unless they are nil.
+func ObjectsFromPathWithVariables(path, dir string, variables map[string]string) ([]client.Object, error) {
 	if http.IsURL(path) {
-		apply, err := http.ToObjects(path)
+		apply, err := http.ToObjectsWithVariables(path, variables)
 		if err != nil {
 			return nil, err
 		}
@@ -683,7 +1255,7 @@ func ObjectsFromPath(path, dir string) ([]client.Object, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to find YAML files in %s: %w", cPath, err)
 	}
-	apply, err := kfile.ToObjects(paths)
+	apply, err := kfile.ToObjectsWithVariables(paths, variables)
 	if err != nil {
 		return nil, err
 	}
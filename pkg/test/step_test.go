@@ -2,21 +2,34 @@ package test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/stackabletech/kuttl/pkg/expressions"
 	testutils "github.com/stackabletech/kuttl/pkg/test/utils"
 )
 
@@ -89,6 +102,192 @@ func TestStepCreate(t *testing.T) {
 	assert.True(t, k8serrors.IsNotFound(cl.Get(context.TODO(), testutils.ObjectKey(actual), actual)))
 }
 
+func TestStepCreateExpectedRejection(t *testing.T) {
+	withAnnotations := func(name string, annotations map[string]string) *unstructured.Unstructured {
+		pod := testutils.NewPod(name, testNamespace)
+		pod.SetAnnotations(annotations)
+		return pod
+	}
+
+	for _, test := range []struct {
+		name string
+		obj  *unstructured.Unstructured
+		err  string
+	}{
+		{
+			name: "rejected",
+			obj:  withAnnotations("invalid", map[string]string{harness.ExpectRejectionAnnotation: "true"}),
+		},
+		{
+			name: "rejected with code and message",
+			obj: withAnnotations("invalid", map[string]string{
+				harness.ExpectRejectionCodeAnnotation:    "422",
+				harness.ExpectRejectionMessageAnnotation: "restartPolicy: Unsupported value",
+			}),
+		},
+		{
+			name: "accepted",
+			obj:  withAnnotations("valid", map[string]string{harness.ExpectRejectionAnnotation: "true"}),
+			err:  "resource Pod:world/valid: expected to be rejected, but was accepted",
+		},
+		{
+			name: "not expected to be rejected",
+			obj:  withAnnotations("valid", map[string]string{harness.ExpectRejectionAnnotation: "false"}),
+		},
+		{
+			name: "unexpected code",
+			obj:  withAnnotations("invalid", map[string]string{harness.ExpectRejectionCodeAnnotation: "403"}),
+			err:  `resource Pod:world/invalid: expected rejection with status code 403, got status code 422: Pod "invalid" is invalid: spec.restartPolicy: Unsupported value: "Sometimes": supported values: "Always", "OnFailure", "Never"`,
+		},
+		{
+			name: "unexpected message",
+			obj:  withAnnotations("invalid", map[string]string{harness.ExpectRejectionMessageAnnotation: "denied by webhook"}),
+			err:  `resource Pod:world/invalid: expected rejection message to match "denied by webhook", got: Pod "invalid" is invalid: spec.restartPolicy: Unsupported value: "Sometimes": supported values: "Always", "OnFailure", "Never"`,
+		},
+		{
+			name: "connection failure",
+			obj:  withAnnotations("unreachable", map[string]string{harness.ExpectRejectionAnnotation: "true"}),
+			err:  "resource Pod:world/unreachable: expected to be rejected by the API server, got: connection reset by peer",
+		},
+		{
+			name: "timeout",
+			obj:  withAnnotations("slow", map[string]string{harness.ExpectRejectionMessageAnnotation: "."}),
+			err:  "resource Pod:world/slow: expected to be rejected by the API server, got: context deadline exceeded",
+		},
+		{
+			name: "invalid annotation",
+			obj:  withAnnotations("invalid", map[string]string{harness.ExpectRejectionAnnotation: "yes"}),
+			err:  `resource Pod:world/invalid: annotation kuttl.dev/expect-rejection has invalid value "yes", expected true or false`,
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			// stands in for a validating webhook which rejects pods named invalid
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					switch obj.GetName() {
+					case "unreachable":
+						return errors.New("connection reset by peer")
+					case "slow":
+						<-ctx.Done()
+						return ctx.Err()
+					}
+					if obj.GetName() == "invalid" {
+						return k8serrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, obj.GetName(), field.ErrorList{
+							field.NotSupported(field.NewPath("spec", "restartPolicy"), "Sometimes", []string{"Always", "OnFailure", "Never"}),
+						})
+					}
+					return cl.Create(ctx, obj, opts...)
+				},
+			}).Build()
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				Timeout:         1,
+				Apply:           []client.Object{test.obj},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+
+			errs := step.Create(t, testNamespace)
+			if test.err == "" {
+				assert.Empty(t, errs)
+			} else {
+				require.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], test.err)
+			}
+
+			if test.obj.GetName() == "valid" {
+				// the accepted object is created without the kuttl annotations
+				actual := testutils.NewPod("valid", testNamespace)
+				require.NoError(t, cl.Get(context.TODO(), testutils.ObjectKey(actual), actual))
+				assert.Empty(t, actual.GetAnnotations())
+			}
+		})
+	}
+}
+
+func TestStepCreateApplyStatus(t *testing.T) {
+	for _, applyStatus := range []bool{false, true} {
+		applyStatus := applyStatus
+
+		t.Run(fmt.Sprintf("applyStatus=%t", applyStatus), func(t *testing.T) {
+			deployment := testutils.NewResource("apps/v1", "Deployment", "hello", testNamespace)
+			deployment.Object["status"] = map[string]interface{}{"replicas": int64(3), "readyReplicas": int64(3)}
+
+			existing := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: testNamespace}}
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing).WithStatusSubresource(existing).Build()
+
+			step := Step{
+				Logger:          testutils.NewTestLogger(t, ""),
+				Step:            &harness.TestStep{ApplyStatus: applyStatus},
+				Apply:           []client.Object{deployment.DeepCopy()},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+			assert.Empty(t, step.Create(t, testNamespace))
+
+			actual := &appsv1.Deployment{}
+			require.NoError(t, cl.Get(context.TODO(), testutils.ObjectKey(deployment), actual))
+			if applyStatus {
+				assert.Equal(t, int32(3), actual.Status.ReadyReplicas)
+			} else {
+				// the status of the update is dropped like by the API server
+				assert.Equal(t, int32(0), actual.Status.ReadyReplicas)
+			}
+		})
+	}
+}
+
+func TestStepPatch(t *testing.T) {
+	annotated := testutils.NewPod("annotated", testNamespace)
+	annotated.SetAnnotations(map[string]string{"restart": "now", "keep": "me"})
+	labeled := testutils.NewPod("labeled", testNamespace)
+	labeled.SetLabels(map[string]string{"app": "zk"})
+	otherNamespace := testutils.NewPod("labeled", "default")
+	otherNamespace.SetLabels(map[string]string{"app": "zk"})
+
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(annotated, labeled, otherNamespace).Build()
+
+	ref := func(name, namespace string, labels map[string]string) harness.ObjectReference {
+		return harness.ObjectReference{
+			ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: name, Namespace: namespace},
+			Labels:          labels,
+		}
+	}
+	raw := func(patch string) *apiextv1.JSON { return &apiextv1.JSON{Raw: []byte(patch)} }
+
+	step := Step{
+		Logger: testutils.NewTestLogger(t, ""),
+		Step: &harness.TestStep{
+			Patch: []harness.TestPatch{
+				{ObjectReference: ref("annotated", "", nil), MergePatch: raw(`{"metadata": {"annotations": {"restart": null}}}`)},
+				{ObjectReference: ref("", "", map[string]string{"app": "zk"}), JSONPatch: raw(`[{"op": "add", "path": "/metadata/labels/patched", "value": "json"}]`)},
+				{ObjectReference: ref("labeled", "default", nil), StrategicMergePatch: raw(`{"metadata": {"labels": {"patched": "strategic"}}}`)},
+				{ObjectReference: ref("", "", map[string]string{"app": "hdfs"}), MergePatch: raw(`{"metadata": {"labels": {"patched": "merge"}}}`)},
+				{ObjectReference: ref("missing", "", nil), MergePatch: raw(`{"metadata": {"labels": {"patched": "merge"}}}`)},
+			},
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	errs := step.Patch(testNamespace)
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "patch v1/Pod with labels map[app:hdfs]: no resources matched")
+	assert.EqualError(t, errs[1], `patch Pod:world/missing: pods "missing" not found`)
+
+	assert.Nil(t, cl.Get(context.TODO(), testutils.ObjectKey(annotated), annotated))
+	assert.Equal(t, map[string]string{"keep": "me"}, annotated.GetAnnotations())
+
+	assert.Nil(t, cl.Get(context.TODO(), testutils.ObjectKey(labeled), labeled))
+	assert.Equal(t, map[string]string{"app": "zk", "patched": "json"}, labeled.GetLabels())
+
+	assert.Nil(t, cl.Get(context.TODO(), testutils.ObjectKey(otherNamespace), otherNamespace))
+	assert.Equal(t, map[string]string{"app": "zk", "patched": "strategic"}, otherNamespace.GetLabels())
+}
+
 // Verify that the DeleteExisting method properly cleans up resources during a test step.
 func TestStepDeleteExisting(t *testing.T) {
 	podToDelete := testutils.NewPod("delete-me", testNamespace)
@@ -204,6 +403,109 @@ func TestCheckResource(t *testing.T) {
 			expected:    testutils.NewPod("hello", ""),
 			shouldError: true,
 		},
+		{
+			testName: "resource matches with matcher",
+			actual: []runtime.Object{testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"containers":    nil,
+				"restartPolicy": "OnFailure",
+			})},
+			expected: testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"restartPolicy":      "(regex ^On)",
+				"serviceAccountName": "(absent)",
+			}),
+		},
+		{
+			testName: "unordered list match",
+			actual: []runtime.Object{testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "main", "image": "nginx"},
+					map[string]interface{}{"name": "sidecar", "image": "busybox"},
+				},
+			})},
+			expected: testutils.WithAnnotations(testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "sidecar"},
+				},
+			}), map[string]string{harness.ListMatchingAnnotation: harness.ListMatchingUnordered}),
+		},
+		{
+			testName: "strict list match",
+			actual: []runtime.Object{testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "main", "image": "nginx"},
+					map[string]interface{}{"name": "sidecar", "image": "busybox"},
+				},
+			})},
+			expected: testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "sidecar"},
+				},
+			}),
+			shouldError: true,
+		},
+		{
+			testName: "semantic comparison",
+			actual: []runtime.Object{testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "main", "resources": map[string]interface{}{
+						"limits": map[string]interface{}{"cpu": "1", "memory": "1Gi"},
+					}},
+				},
+			})},
+			expected: testutils.WithAnnotations(testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "main", "resources": map[string]interface{}{
+						"limits": map[string]interface{}{"cpu": "1000m", "memory": "1024Mi"},
+					}},
+				},
+			}), map[string]string{harness.SemanticComparisonAnnotation: "spec.containers.resources"}),
+		},
+		{
+			testName: "exact count matches",
+			actual: []runtime.Object{
+				testutils.WithLabels(t, testutils.NewPod("zk-0", ""), map[string]string{"app": "zk"}),
+				testutils.WithLabels(t, testutils.NewPod("zk-1", ""), map[string]string{"app": "zk"}),
+				testutils.WithLabels(t, testutils.NewPod("other", ""), map[string]string{"app": "other"}),
+			},
+			expected: testutils.WithAnnotations(testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "zk"}), map[string]string{harness.CountAnnotation: "2"}),
+		},
+		{
+			testName: "exact count does not match",
+			actual: []runtime.Object{
+				testutils.WithLabels(t, testutils.NewPod("zk-0", ""), map[string]string{"app": "zk"}),
+				testutils.WithLabels(t, testutils.NewPod("other", ""), map[string]string{"app": "other"}),
+			},
+			expected:    testutils.WithAnnotations(testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "zk"}), map[string]string{harness.CountAnnotation: "2"}),
+			shouldError: true,
+		},
+		{
+			testName: "zero count matches no resources",
+			actual:   []runtime.Object{testutils.WithLabels(t, testutils.NewPod("other", ""), map[string]string{"app": "other"})},
+			expected: testutils.WithAnnotations(testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "zk"}), map[string]string{harness.CountAnnotation: "0"}),
+		},
+		{
+			testName: "max count exceeded",
+			actual: []runtime.Object{
+				testutils.WithLabels(t, testutils.NewPod("zk-0", ""), map[string]string{"app": "zk"}),
+				testutils.WithLabels(t, testutils.NewPod("zk-1", ""), map[string]string{"app": "zk"}),
+			},
+			expected:    testutils.WithAnnotations(testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "zk"}), map[string]string{harness.MaxCountAnnotation: "1"}),
+			shouldError: true,
+		},
+		{
+			testName: "min count satisfied",
+			actual: []runtime.Object{
+				testutils.WithLabels(t, testutils.NewPod("zk-0", ""), map[string]string{"app": "zk"}),
+				testutils.WithLabels(t, testutils.NewPod("zk-1", ""), map[string]string{"app": "zk"}),
+			},
+			expected: testutils.WithAnnotations(testutils.WithLabels(t, testutils.NewPod("", ""), map[string]string{"app": "zk"}), map[string]string{harness.MinCountAnnotation: "1"}),
+		},
+		{
+			testName:    "count with name",
+			actual:      []runtime.Object{testutils.NewPod("hello", "")},
+			expected:    testutils.WithAnnotations(testutils.NewPod("hello", ""), map[string]string{harness.CountAnnotation: "1"}),
+			shouldError: true,
+		},
 	} {
 		test := test
 
@@ -234,6 +536,32 @@ func TestCheckResource(t *testing.T) {
 	}
 }
 
+func TestCheckResourceReportsAllMismatches(t *testing.T) {
+	actual := testutils.WithSpec(t, testutils.NewPod("hello", testNamespace), map[string]interface{}{
+		"restartPolicy":      "Always",
+		"serviceAccountName": "default",
+	})
+
+	step := Step{
+		Logger: testutils.NewTestLogger(t, ""),
+		Client: func(bool) (client.Client, error) {
+			return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(actual).Build(), nil
+		},
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+	}
+
+	errors := step.CheckResource(testutils.WithSpec(t, testutils.NewPod("hello", ""), map[string]interface{}{
+		"restartPolicy":      "Never",
+		"serviceAccountName": "custom",
+	}), testNamespace)
+
+	require.Len(t, errors, 2)
+	assert.Contains(t, errors[0].Error(), "restartPolicy")
+	assert.EqualError(t, errors[1], `resource Pod:world/hello: 2 mismatches:
+  .spec.restartPolicy: value mismatch, expected: Never != actual: Always
+  .spec.serviceAccountName: value mismatch, expected: custom != actual: default`)
+}
+
 func TestCheckResourceAbsent(t *testing.T) {
 	for _, test := range []struct {
 		name        string
@@ -279,6 +607,29 @@ func TestCheckResourceAbsent(t *testing.T) {
 			actual:   []runtime.Object{testutils.NewPod("other", "")},
 			expected: testutils.NewPod("hello", ""),
 		},
+		{
+			name: "resource matches with matcher",
+			actual: []runtime.Object{
+				testutils.NewV1Pod("pod1", "", "val1"),
+			},
+			expected:    testutils.WithSpec(t, testutils.NewPod("", ""), map[string]interface{}{"serviceAccountName": "(regex ^val)"}),
+			shouldError: true,
+			expectedErr: "resource /v1, Kind=Pod pod1 matched error assertion",
+		},
+		{
+			name:        "count constraint",
+			actual:      []runtime.Object{testutils.NewPod("hello", "")},
+			expected:    testutils.WithAnnotations(testutils.NewPod("hello", ""), map[string]string{harness.CountAnnotation: "1"}),
+			shouldError: true,
+			expectedErr: "resource Pod:world/hello: count constraints can not be used in errors files",
+		},
+		{
+			name:        "invalid count constraint",
+			actual:      []runtime.Object{testutils.NewPod("hello", "")},
+			expected:    testutils.WithAnnotations(testutils.NewPod("hello", ""), map[string]string{harness.CountAnnotation: "one"}),
+			shouldError: true,
+			expectedErr: `resource Pod:world/hello: annotation kuttl.dev/count has invalid value "one", expected a non-negative integer`,
+		},
 	} {
 		test := test
 
@@ -312,6 +663,68 @@ func TestCheckResourceAbsent(t *testing.T) {
 	}
 }
 
+func TestCheckAssertExpressions(t *testing.T) {
+	pod := testutils.WithSpec(t, testutils.NewPod("hello", testNamespace), map[string]interface{}{
+		"containers":    nil,
+		"restartPolicy": "OnFailure",
+	})
+
+	for _, test := range []struct {
+		name        string
+		assert      *harness.TestAssert
+		expectedErr string
+	}{
+		{
+			name: "expression holds",
+			assert: &harness.TestAssert{
+				ResourceRefs: []harness.TestResourceRef{{APIVersion: "v1", Kind: "Pod", Name: "hello", Ref: "pod"}},
+				AssertAll:    []*harness.Assertion{{CELExpression: "pod.spec.restartPolicy == 'OnFailure'"}},
+			},
+		},
+		{
+			name: "expression does not hold",
+			assert: &harness.TestAssert{
+				ResourceRefs: []harness.TestResourceRef{{APIVersion: "v1", Kind: "Pod", Name: "hello", Ref: "pod"}},
+				AssertAll:    []*harness.Assertion{{CELExpression: "pod.spec.restartPolicy == 'Never'"}},
+			},
+			expectedErr: `assertion "pod.spec.restartPolicy == 'Never'" failed: evaluated to false (pod.spec.restartPolicy = OnFailure)`,
+		},
+		{
+			name: "referenced resource does not exist",
+			assert: &harness.TestAssert{
+				ResourceRefs: []harness.TestResourceRef{{APIVersion: "v1", Kind: "Pod", Name: "other", Ref: "pod"}},
+				AssertAll:    []*harness.Assertion{{CELExpression: "pod.spec.restartPolicy == 'OnFailure'"}},
+			},
+			expectedErr: `resource ref "pod": pods "other" not found`,
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			programs, err := expressions.LoadPrograms(test.assert)
+			assert.NoError(t, err)
+
+			step := Step{
+				Assert:   test.assert,
+				Programs: programs,
+				Logger:   testutils.NewTestLogger(t, ""),
+				Client: func(bool) (client.Client, error) {
+					return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pod).Build(), nil
+				},
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+			}
+
+			errs := step.CheckAssertExpressions(testNamespace)
+			if test.expectedErr == "" {
+				assert.Empty(t, errs)
+			} else {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], test.expectedErr)
+			}
+		})
+	}
+}
+
 func TestRun(t *testing.T) {
 	for _, test := range []struct {
 		testName     string
@@ -395,6 +808,185 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestCheckConsistently(t *testing.T) {
+	for _, test := range []struct {
+		testName     string
+		shouldError  bool
+		updateMethod func(*testing.T, client.Client)
+	}{
+		{
+			testName: "state holds",
+		},
+		{
+			testName:    "state breaks",
+			shouldError: true,
+			updateMethod: func(t *testing.T, client client.Client) {
+				pod := testutils.NewPod("hello", testNamespace)
+				assert.Nil(t, client.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "hello"}, pod))
+				assert.Nil(t, client.Status().Update(context.TODO(), testutils.WithStatus(t, pod, map[string]interface{}{
+					"phase": "Failed",
+				})))
+			},
+		},
+	} {
+		test := test
+
+		t.Run(test.testName, func(t *testing.T) {
+			pod := testutils.WithStatus(t, testutils.NewPod("hello", testNamespace), map[string]interface{}{
+				"phase": "Running",
+			})
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pod).WithStatusSubresource(pod).Build()
+
+			step := Step{
+				Asserts: []client.Object{
+					testutils.WithStatus(t, testutils.NewPod("hello", ""), map[string]interface{}{
+						"phase": "Running",
+					}),
+				},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				Logger:          testutils.NewTestLogger(t, ""),
+			}
+
+			changes := make(chan struct{}, 1)
+			if test.updateMethod != nil {
+				go func() {
+					time.Sleep(500 * time.Millisecond)
+					test.updateMethod(t, cl)
+					changes <- struct{}{}
+				}()
+			}
+
+			start := time.Now()
+			errors := step.CheckConsistently(testNamespace, 2*time.Second, changes)
+
+			if test.shouldError {
+				assert.NotEqual(t, []error{}, errors)
+				assert.Contains(t, errors[0].Error(), "asserted state did not hold for 2s")
+				assert.Less(t, time.Since(start), 2*time.Second)
+			} else {
+				assert.Equal(t, []error{}, errors)
+				assert.GreaterOrEqual(t, time.Since(start), 2*time.Second)
+			}
+		})
+	}
+}
+
+func TestCheckConsistentlyMinInterval(t *testing.T) {
+	checks := 0
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(testutils.WithNamespace(testutils.NewPod("hello", ""), testNamespace)).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, cl client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				checks++
+				return cl.Get(ctx, key, obj, opts...)
+			},
+		}).Build()
+
+	step := Step{
+		Asserts:         []client.Object{testutils.NewPod("hello", "")},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		Logger:          testutils.NewTestLogger(t, ""),
+	}
+
+	// a closed channel stands in for a storm of changes, which must not trigger back-to-back checks
+	changes := make(chan struct{})
+	close(changes)
+
+	assert.Equal(t, []error{}, step.CheckConsistently(testNamespace, time.Second, changes))
+	assert.LessOrEqual(t, checks, int(time.Second/minCheckInterval)+1)
+	assert.GreaterOrEqual(t, checks, 2)
+}
+
+func TestRunConsistentlyWithinTimeout(t *testing.T) {
+	pod := testutils.WithNamespace(testutils.NewPod("hello", ""), testNamespace)
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build()
+
+	for _, test := range []struct {
+		name        string
+		expected    client.Object
+		shouldError bool
+		minDuration time.Duration
+		maxDuration time.Duration
+	}{
+		{
+			name:        "state holds",
+			expected:    testutils.NewPod("hello", ""),
+			minDuration: 2 * time.Second,
+			maxDuration: 3 * time.Second,
+		},
+		{
+			// the state has to be reached before the consistently window would exceed the timeout
+			name:        "state not reached",
+			expected:    testutils.NewPod("missing", ""),
+			shouldError: true,
+			maxDuration: 2 * time.Second,
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			step := Step{
+				Asserts: []client.Object{test.expected},
+				Assert: &harness.TestAssert{
+					Timeout:      3,
+					Consistently: 2,
+				},
+				Client:          func(bool) (client.Client, error) { return cl, nil },
+				DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+				Logger:          testutils.NewTestLogger(t, ""),
+			}
+
+			start := time.Now()
+			errors := step.Run(t, testNamespace)
+
+			assert.Equal(t, test.shouldError, len(errors) > 0)
+			assert.GreaterOrEqual(t, time.Since(start), test.minDuration)
+			assert.Less(t, time.Since(start), test.maxDuration)
+		})
+	}
+}
+
+func TestLoadYAMLConsistentlyTimeout(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "00-assert.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestAssert
+timeout: 30
+consistently: 30
+`), 0600))
+
+	assert.EqualError(t, (&Step{Dir: dir, Timeout: 60}).LoadYAML(file),
+		"consistently (30 seconds) in "+file+" must be shorter than the timeout of the step (30 seconds)")
+}
+
+func TestRunFailFast(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	step := Step{
+		Apply: []client.Object{
+			testutils.NewPod("hello", ""),
+		},
+		Errors: []client.Object{
+			testutils.NewPod("hello", ""),
+		},
+		Assert: &harness.TestAssert{
+			Timeout:  10,
+			FailFast: true,
+		},
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		Logger:          testutils.NewTestLogger(t, ""),
+	}
+
+	start := time.Now()
+	errors := step.Run(t, testNamespace)
+
+	assert.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, errors, 2)
+	assert.Contains(t, errors[0].Error(), "name: hello")
+	assert.EqualError(t, errors[1], "forbidden state observed: resource /v1, Kind=Pod hello matched error assertion")
+}
+
 func TestPopulateObjectsByFileName(t *testing.T) {
 	for _, tt := range []struct {
 		fileName                   string
@@ -428,3 +1020,253 @@ func TestPopulateObjectsByFileName(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadYAMLListMatching(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "00-assert.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestFile
+listMatching: Unordered
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: defaulted
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: overridden
+  annotations:
+    kuttl.dev/list-matching: Strict
+`), 0600))
+
+	step := &Step{Dir: dir}
+	assert.NoError(t, step.LoadYAML(file))
+	assert.Len(t, step.Asserts, 2)
+	assert.Equal(t, harness.ListMatchingUnordered, step.Asserts[0].GetAnnotations()[harness.ListMatchingAnnotation])
+	assert.Equal(t, harness.ListMatchingStrict, step.Asserts[1].GetAnnotations()[harness.ListMatchingAnnotation])
+}
+
+func TestLoadYAMLTestRunSelector(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		testFile string
+		skipped  bool
+	}{
+		{
+			// a TestFile which only configures list matching does not restrict the test runs
+			name:     "no selector",
+			testFile: "listMatching: Unordered",
+		},
+		{
+			name:     "empty selector",
+			testFile: "testRunSelector: {}",
+		},
+		{
+			name:     "matching selector",
+			testFile: "testRunSelector:\n  matchLabels:\n    flavor: a",
+		},
+		{
+			name:     "selector not matching",
+			testFile: "testRunSelector:\n  matchLabels:\n    flavor: b",
+			skipped:  true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "00-assert.yaml")
+			require.NoError(t, os.WriteFile(file, []byte("apiVersion: kuttl.dev/v1beta1\nkind: TestFile\n"+tt.testFile+`
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: hello
+`), 0600))
+
+			step := &Step{Dir: dir, TestRunLabels: labels.Set{"flavor": "a"}}
+			require.NoError(t, step.LoadYAML(file))
+			if tt.skipped {
+				assert.Empty(t, step.Asserts)
+			} else {
+				assert.Len(t, step.Asserts, 1)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLApplyOptions(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		testStep string
+		expected testutils.ApplyOptions
+		err      string
+	}{
+		{
+			name:     "suite defaults",
+			expected: testutils.ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "stackable"},
+		},
+		{
+			name:     "force conflicts",
+			testStep: "forceConflicts: true",
+			expected: testutils.ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "stackable", ForceConflicts: true},
+		},
+		{
+			name:     "other strategy",
+			testStep: "applyStrategy: Replace",
+			expected: testutils.ApplyOptions{Strategy: harness.ApplyStrategyReplace},
+		},
+		{
+			name:     "invalid strategy",
+			testStep: "applyStrategy: Create",
+			err:      `unknown apply strategy "Create", expected one of Merge, StrategicMerge, ServerSide or Replace`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "00-step.yaml")
+			assert.NoError(t, os.WriteFile(file, []byte("apiVersion: kuttl.dev/v1beta1\nkind: TestStep\n"+tt.testStep+"\n"), 0600))
+
+			step := &Step{Dir: dir, ApplyOptions: testutils.ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "stackable"}}
+			err := step.LoadYAML(file)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, step.ApplyOptions)
+		})
+	}
+}
+
+func TestLoadYAMLPatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "00-step.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestStep
+patch:
+- apiVersion: apps/v1
+  kind: StatefulSet
+  name: zk
+  jsonPatch:
+  - op: replace
+    path: /spec/replicas
+    value: 0
+- apiVersion: v1
+  kind: Pod
+  labels:
+    app: zk
+  mergePatch:
+    metadata:
+      annotations:
+        restart: null
+`), 0600))
+
+	step := &Step{Dir: dir}
+	require.NoError(t, step.LoadYAML(file))
+	require.Len(t, step.Step.Patch, 2)
+
+	patchType, data := step.Step.Patch[0].Data()
+	assert.Equal(t, types.JSONPatchType, patchType)
+	assert.JSONEq(t, `[{"op": "replace", "path": "/spec/replicas", "value": 0}]`, string(data))
+
+	patchType, data = step.Step.Patch[1].Data()
+	assert.Equal(t, types.MergePatchType, patchType)
+	assert.JSONEq(t, `{"metadata": {"annotations": {"restart": null}}}`, string(data))
+	assert.Equal(t, map[string]string{"app": "zk"}, step.Step.Patch[1].Labels)
+
+	assert.NoError(t, os.WriteFile(file, []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestStep
+patch:
+- apiVersion: apps/v1
+  kind: StatefulSet
+  name: zk
+`), 0600))
+	assert.ErrorContains(t, (&Step{Dir: dir}).LoadYAML(file), "invalid patch 0 in "+file+": patch requires exactly one of jsonPatch, mergePatch or strategicMergePatch")
+}
+
+func TestStepExpand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "00-step.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestStep
+expandVariables: true
+apply:
+- shared/
+`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00-assert.yaml"), []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: $NAMESPACE-reader
+subjects:
+- kind: ServiceAccount
+  name: reader
+  namespace: $NAMESPACE
+`), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "shared"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shared", "statefulset.yaml"), []byte(`apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: zk
+  annotations:
+    price: $$5
+spec:
+  replicas: $REPLICAS
+`), 0600))
+
+	step := &Step{Dir: dir}
+	require.NoError(t, step.LoadYAML(filepath.Join(dir, "00-assert.yaml")))
+	require.NoError(t, step.LoadYAML(file))
+	assert.True(t, step.ExpandVariables)
+	assert.Equal(t, "$NAMESPACE-reader", step.Asserts[0].GetName())
+
+	step.Variables = map[string]string{"REPLICAS": "3"}
+	require.NoError(t, step.Expand(testNamespace))
+
+	require.Len(t, step.Asserts, 1)
+	assert.Equal(t, "world-reader", step.Asserts[0].GetName())
+	subjects, _, _ := unstructured.NestedSlice(step.Asserts[0].(*unstructured.Unstructured).Object, "subjects")
+	assert.Equal(t, "world", subjects[0].(map[string]interface{})["namespace"])
+
+	require.Len(t, step.Apply, 1)
+	sts := step.Apply[0].(*unstructured.Unstructured)
+	replicas, _, _ := unstructured.NestedInt64(sts.Object, "spec", "replicas")
+	assert.Equal(t, int64(3), replicas)
+	assert.Equal(t, "$5", sts.GetAnnotations()["price"])
+
+	// the files can be expanded again with other values
+	step.Variables["REPLICAS"] = "5"
+	require.NoError(t, step.Expand(testNamespace))
+	require.Len(t, step.Apply, 1)
+	replicas, _, _ = unstructured.NestedInt64(step.Apply[0].(*unstructured.Unstructured).Object, "spec", "replicas")
+	assert.Equal(t, int64(5), replicas)
+}
+
+func TestStepExpandCommands(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "00-step.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`apiVersion: kuttl.dev/v1beta1
+kind: TestStep
+expandVariables: true
+commands:
+- command: sh -c 'echo "$$1" > home' sh '$$HOME'
+`), 0600))
+
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	step := &Step{
+		Dir:             dir,
+		Client:          func(bool) (client.Client, error) { return cl, nil },
+		DiscoveryClient: func() (discovery.DiscoveryInterface, error) { return testutils.FakeDiscoveryClient(), nil },
+		Logger:          testutils.NewTestLogger(t, ""),
+	}
+	require.NoError(t, step.LoadYAML(file))
+	require.NoError(t, step.Expand(testNamespace))
+	require.Empty(t, step.Run(t, testNamespace))
+
+	// the command is only expanded when it is run, so $$ reaches the shell as $
+	home, err := os.ReadFile(filepath.Join(dir, "home"))
+	require.NoError(t, err)
+	assert.Equal(t, "$HOME\n", string(home))
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	apijson "k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// DefaultFieldManager is the field manager of server-side applies if none is configured.
+const DefaultFieldManager = "kuttl"
+
+// ApplyOptions configure how CreateOrUpdateWithOptions creates and updates objects.
+type ApplyOptions struct {
+	// Strategy is one of the harness.ApplyStrategy constants. Defaults to harness.ApplyStrategyMerge.
+	Strategy string
+	// FieldManager is the field manager of server-side applies. Defaults to DefaultFieldManager.
+	FieldManager string
+	// ForceConflicts makes server-side applies take ownership of fields managed by other field managers.
+	ForceConflicts bool
+}
+
+// Validate checks that the strategy is known and that the field manager options are only set for server-side applies.
+func (o ApplyOptions) Validate() error {
+	switch o.Strategy {
+	case "", harness.ApplyStrategyMerge, harness.ApplyStrategyStrategicMerge, harness.ApplyStrategyReplace:
+		if o.FieldManager != "" || o.ForceConflicts {
+			return fmt.Errorf("fieldManager and forceConflicts require apply strategy %s", harness.ApplyStrategyServerSide)
+		}
+	case harness.ApplyStrategyServerSide:
+	default:
+		return fmt.Errorf("unknown apply strategy %q, expected one of %s, %s, %s or %s", o.Strategy,
+			harness.ApplyStrategyMerge, harness.ApplyStrategyStrategicMerge, harness.ApplyStrategyServerSide, harness.ApplyStrategyReplace)
+	}
+	return nil
+}
+
+// Merge returns the options with the fields set in override replacing those of o.
+func (o ApplyOptions) Merge(override ApplyOptions) ApplyOptions {
+	if override.Strategy != "" && override.Strategy != o.Strategy {
+		// the field manager options of another strategy do not apply
+		o = ApplyOptions{Strategy: override.Strategy}
+	}
+	if override.FieldManager != "" {
+		o.FieldManager = override.FieldManager
+	}
+	o.ForceConflicts = o.ForceConflicts || override.ForceConflicts
+	return o
+}
+
+func (o ApplyOptions) strategy() string {
+	if o.Strategy == "" {
+		return harness.ApplyStrategyMerge
+	}
+	return o.Strategy
+}
+
+func (o ApplyOptions) patchOptions() []client.PatchOption {
+	fieldManager := o.FieldManager
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+	patchOpts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if o.ForceConflicts {
+		patchOpts = append(patchOpts, client.ForceOwnership)
+	}
+	return patchOpts
+}
+
+// update updates the existing object actual to expected with the strategy given in opts.
+func update(ctx context.Context, cl client.Client, actual, expected client.Object, opts ApplyOptions) error {
+	switch opts.strategy() {
+	case harness.ApplyStrategyServerSide:
+		return cl.Patch(ctx, expected, client.Apply, opts.patchOptions()...)
+	case harness.ApplyStrategyReplace:
+		if err := PatchObject(actual, expected); err != nil {
+			return err
+		}
+		return cl.Update(ctx, expected)
+	}
+
+	patchType := types.MergePatchType
+	if opts.strategy() == harness.ApplyStrategyStrategicMerge {
+		patchType = types.StrategicMergePatchType
+	}
+
+	if err := PatchObject(actual, expected); err != nil {
+		return err
+	}
+	expectedBytes, err := apijson.Marshal(expected)
+	if err != nil {
+		return err
+	}
+	return cl.Patch(ctx, actual, client.RawPatch(patchType, expectedBytes))
+}
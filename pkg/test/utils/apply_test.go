@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+func TestApplyOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ApplyOptions
+		err  string
+	}{
+		{name: "default"},
+		{name: "replace", opts: ApplyOptions{Strategy: harness.ApplyStrategyReplace}},
+		{name: "server-side", opts: ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "stackable", ForceConflicts: true}},
+		{name: "unknown strategy", opts: ApplyOptions{Strategy: "Apply"}, err: `unknown apply strategy "Apply", expected one of Merge, StrategicMerge, ServerSide or Replace`},
+		{name: "field manager without server-side", opts: ApplyOptions{Strategy: harness.ApplyStrategyMerge, FieldManager: "stackable"}, err: "fieldManager and forceConflicts require apply strategy ServerSide"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestApplyOptionsMerge(t *testing.T) {
+	suite := ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "stackable"}
+
+	assert.Equal(t, suite, suite.Merge(ApplyOptions{}))
+	assert.Equal(t, ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "stackable", ForceConflicts: true},
+		suite.Merge(ApplyOptions{Strategy: harness.ApplyStrategyServerSide, ForceConflicts: true}))
+	assert.Equal(t, ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "test"}, suite.Merge(ApplyOptions{FieldManager: "test"}))
+	assert.Equal(t, ApplyOptions{Strategy: harness.ApplyStrategyReplace}, suite.Merge(ApplyOptions{Strategy: harness.ApplyStrategyReplace}))
+}
+
+func TestCreateOrUpdateWithOptions(t *testing.T) {
+	existing := WithSpec(t, NewPod("hello", "default"), map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "main", "image": "zookeeper:3.8"},
+			map[string]interface{}{"name": "sidecar", "image": "vector:0.33"},
+		},
+		"restartPolicy": "Always",
+	})
+	update := WithSpec(t, NewPod("hello", "default"), map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "main", "image": "zookeeper:3.9"},
+		},
+	})
+
+	tests := []struct {
+		strategy      string
+		containers    []interface{}
+		restartPolicy interface{}
+	}{
+		{
+			// the list is replaced, other fields are kept
+			strategy: harness.ApplyStrategyMerge,
+			containers: []interface{}{
+				map[string]interface{}{"name": "main", "image": "zookeeper:3.9"},
+			},
+			restartPolicy: "Always",
+		},
+		{
+			// list entries are merged by name
+			strategy: harness.ApplyStrategyStrategicMerge,
+			containers: []interface{}{
+				map[string]interface{}{"name": "main", "image": "zookeeper:3.9"},
+				map[string]interface{}{"name": "sidecar", "image": "vector:0.33"},
+			},
+			restartPolicy: "Always",
+		},
+		{
+			// fields which are not set are removed
+			strategy: harness.ApplyStrategyReplace,
+			containers: []interface{}{
+				map[string]interface{}{"name": "main", "image": "zookeeper:3.9"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.strategy, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(existing.DeepCopy()).Build()
+
+			updated, err := CreateOrUpdateWithOptions(context.TODO(), cl, update.DeepCopy(), true, ApplyOptions{Strategy: tt.strategy})
+			require.NoError(t, err)
+			assert.True(t, updated)
+
+			actual := NewPod("hello", "default")
+			require.NoError(t, cl.Get(context.TODO(), ObjectKey(actual), actual))
+			containers, _, _ := unstructured.NestedSlice(actual.Object, "spec", "containers")
+			for _, container := range containers {
+				// drop the empty resources of the typed pod the fake client stores
+				delete(container.(map[string]interface{}), "resources")
+			}
+			assert.Equal(t, tt.containers, containers)
+			restartPolicy, _, _ := unstructured.NestedFieldNoCopy(actual.Object, "spec", "restartPolicy")
+			assert.Equal(t, tt.restartPolicy, restartPolicy)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// CountConstraint constrains the number of objects which match an expected object.
+// A negative Max means that there is no upper bound.
+type CountConstraint struct {
+	Min int
+	Max int
+}
+
+// CountConstraintFromAnnotations builds the CountConstraint configured by the kuttl.dev/ annotations of an
+// expected object. It returns nil if no count annotation is set.
+// If only a maximum is given, zero matching objects satisfy the constraint.
+func CountConstraintFromAnnotations(annotations map[string]string) (*CountConstraint, error) {
+	count, hasCount, err := countAnnotation(annotations, harness.CountAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	minCount, hasMin, err := countAnnotation(annotations, harness.MinCountAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	maxCount, hasMax, err := countAnnotation(annotations, harness.MaxCountAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case hasCount && (hasMin || hasMax):
+		return nil, fmt.Errorf("annotation %s can not be combined with %s or %s", harness.CountAnnotation, harness.MinCountAnnotation, harness.MaxCountAnnotation)
+	case hasCount:
+		return &CountConstraint{Min: count, Max: count}, nil
+	case hasMin || hasMax:
+		c := &CountConstraint{Min: minCount, Max: -1}
+		if hasMax {
+			c.Max = maxCount
+		}
+		if c.Max >= 0 && c.Min > c.Max {
+			return nil, fmt.Errorf("annotation %s (%d) is greater than %s (%d)", harness.MinCountAnnotation, c.Min, harness.MaxCountAnnotation, c.Max)
+		}
+		return c, nil
+	}
+	return nil, nil
+}
+
+func countAnnotation(annotations map[string]string, key string) (int, bool, error) {
+	value, ok := annotations[key]
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, true, fmt.Errorf("annotation %s has invalid value %q, expected a non-negative integer", key, value)
+	}
+	return n, true, nil
+}
+
+// Check returns an error if the number of matching objects violates the constraint.
+func (c *CountConstraint) Check(matching int) error {
+	if matching < c.Min || (c.Max >= 0 && matching > c.Max) {
+		return fmt.Errorf("expected %s matching resources, found %d", c, matching)
+	}
+	return nil
+}
+
+// String describes the constraint, e.g. "exactly 3" or "at least 1 and at most 2".
+func (c *CountConstraint) String() string {
+	switch {
+	case c.Min == c.Max:
+		return fmt.Sprintf("exactly %d", c.Min)
+	case c.Max < 0:
+		return fmt.Sprintf("at least %d", c.Min)
+	case c.Min == 0:
+		return fmt.Sprintf("at most %d", c.Max)
+	}
+	return fmt.Sprintf("at least %d and at most %d", c.Min, c.Max)
+}
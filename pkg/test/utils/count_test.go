@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+func TestCountConstraintFromAnnotations(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		annotations map[string]string
+		expected    *CountConstraint
+		description string
+		err         string
+	}{
+		{
+			name:        "no annotations",
+			annotations: map[string]string{harness.ListMatchingAnnotation: harness.ListMatchingStrict},
+		},
+		{
+			name:        "exact",
+			annotations: map[string]string{harness.CountAnnotation: "3"},
+			expected:    &CountConstraint{Min: 3, Max: 3},
+			description: "exactly 3",
+		},
+		{
+			name:        "min",
+			annotations: map[string]string{harness.MinCountAnnotation: "2"},
+			expected:    &CountConstraint{Min: 2, Max: -1},
+			description: "at least 2",
+		},
+		{
+			name:        "max",
+			annotations: map[string]string{harness.MaxCountAnnotation: "1"},
+			expected:    &CountConstraint{Min: 0, Max: 1},
+			description: "at most 1",
+		},
+		{
+			name:        "min and max",
+			annotations: map[string]string{harness.MinCountAnnotation: "1", harness.MaxCountAnnotation: "3"},
+			expected:    &CountConstraint{Min: 1, Max: 3},
+			description: "at least 1 and at most 3",
+		},
+		{
+			name:        "invalid value",
+			annotations: map[string]string{harness.CountAnnotation: "three"},
+			err:         `annotation kuttl.dev/count has invalid value "three", expected a non-negative integer`,
+		},
+		{
+			name:        "negative value",
+			annotations: map[string]string{harness.MinCountAnnotation: "-1"},
+			err:         `annotation kuttl.dev/min-count has invalid value "-1", expected a non-negative integer`,
+		},
+		{
+			name:        "count combined with min",
+			annotations: map[string]string{harness.CountAnnotation: "1", harness.MinCountAnnotation: "1"},
+			err:         "annotation kuttl.dev/count can not be combined with kuttl.dev/min-count or kuttl.dev/max-count",
+		},
+		{
+			name:        "min greater than max",
+			annotations: map[string]string{harness.MinCountAnnotation: "3", harness.MaxCountAnnotation: "1"},
+			err:         "annotation kuttl.dev/min-count (3) is greater than kuttl.dev/max-count (1)",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := CountConstraintFromAnnotations(tt.annotations)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, c)
+			if c != nil {
+				assert.Equal(t, tt.description, c.String())
+			}
+		})
+	}
+}
+
+func TestCountConstraintCheck(t *testing.T) {
+	c := &CountConstraint{Min: 1, Max: 2}
+	assert.EqualError(t, c.Check(0), "expected at least 1 and at most 2 matching resources, found 0")
+	assert.NoError(t, c.Check(1))
+	assert.NoError(t, c.Check(2))
+	assert.EqualError(t, c.Check(3), "expected at least 1 and at most 2 matching resources, found 3")
+
+	unbounded := &CountConstraint{Min: 0, Max: -1}
+	assert.NoError(t, unbounded.Check(100))
+}
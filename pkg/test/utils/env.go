@@ -0,0 +1,145 @@
+package utils
+
+// Contains the resolution of the environment variables of commands.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// ResolveEnv returns copies of commands whose environment variables are preceded by env and have literal values,
+// so that RunCommands can run them. The values of Secrets and ConfigMaps are read from namespace with the client
+// returned by getClient, which is only called if they are referenced, and relative file paths are relative to dir.
+// The values read from Secrets are returned as well, to be masked in the logs with NewMaskingLogger.
+func ResolveEnv(ctx context.Context, getClient func(forceNew bool) (client.Client, error), namespace, dir string, env []harness.EnvVar, commands []harness.Command) ([]harness.Command, []string, error) {
+	r := &envResolver{ctx: ctx, getClient: getClient, namespace: namespace, dir: dir}
+
+	shared, err := r.resolve(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolved := make([]harness.Command, 0, len(commands))
+	for _, cmd := range commands {
+		cmdEnv, err := r.resolve(cmd.Env)
+		if err != nil {
+			return nil, nil, fmt.Errorf("command %q: %w", cmd.String(), err)
+		}
+		cmd.Env = append(append([]harness.EnvVar{}, shared...), cmdEnv...)
+		resolved = append(resolved, cmd)
+	}
+	return resolved, r.secrets, nil
+}
+
+// envResolver reads the values of environment variables from their sources.
+type envResolver struct {
+	ctx       context.Context
+	getClient func(forceNew bool) (client.Client, error)
+	client    client.Client
+	namespace string
+	dir       string
+	secrets   []string
+}
+
+// resolve returns env with literal values. Environment variables of optional Secrets and ConfigMaps which do not
+// exist are left out.
+func (r *envResolver) resolve(env []harness.EnvVar) ([]harness.EnvVar, error) {
+	resolved := []harness.EnvVar{}
+	for _, envVar := range env {
+		if err := envVar.Validate(); err != nil {
+			return nil, err
+		}
+		if envVar.ValueFrom == nil {
+			resolved = append(resolved, envVar)
+			continue
+		}
+
+		value, ok, err := r.value(envVar.ValueFrom)
+		if err != nil {
+			return nil, fmt.Errorf("environment variable %s: %w", envVar.Name, err)
+		}
+		if ok {
+			resolved = append(resolved, harness.EnvVar{Name: envVar.Name, Value: value})
+		}
+	}
+	return resolved, nil
+}
+
+// value reads the value from source. It returns false if an optional Secret or ConfigMap or its key does not exist.
+func (r *envResolver) value(source *harness.EnvVarSource) (string, bool, error) {
+	switch {
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		secret := &corev1.Secret{}
+		err := r.get(ref.Name, secret)
+		if k8serrors.IsNotFound(err) && isOptional(ref.Optional) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read secret %s/%s: %w", r.namespace, ref.Name, err)
+		}
+		value, ok := secret.Data[ref.Key]
+		if !ok {
+			if isOptional(ref.Optional) {
+				return "", false, nil
+			}
+			return "", false, fmt.Errorf("secret %s/%s has no key %s", r.namespace, ref.Name, ref.Key)
+		}
+		r.secrets = append(r.secrets, string(value))
+		return string(value), true, nil
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		configMap := &corev1.ConfigMap{}
+		err := r.get(ref.Name, configMap)
+		if k8serrors.IsNotFound(err) && isOptional(ref.Optional) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read configmap %s/%s: %w", r.namespace, ref.Name, err)
+		}
+		if value, ok := configMap.Data[ref.Key]; ok {
+			return value, true, nil
+		}
+		if value, ok := configMap.BinaryData[ref.Key]; ok {
+			return string(value), true, nil
+		}
+		if isOptional(ref.Optional) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("configmap %s/%s has no key %s", r.namespace, ref.Name, ref.Key)
+	default:
+		path := source.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, err
+		}
+		return string(data), true, nil
+	}
+}
+
+// get reads the object called name in the namespace of the commands.
+func (r *envResolver) get(name string, obj client.Object) error {
+	if r.client == nil {
+		cl, err := r.getClient(false)
+		if err != nil {
+			return err
+		}
+		r.client = cl
+	}
+	return r.client.Get(r.ctx, client.ObjectKey{Namespace: r.namespace, Name: name}, obj)
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+func TestResolveEnv(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token.txt"), []byte("token"), 0600))
+
+	cl := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "world"}, Data: map[string][]byte{"password": []byte("hunter2")}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "world"}, Data: map[string]string{"storageClass": "standard"}},
+	).Build()
+	getClient := func(bool) (client.Client, error) { return cl, nil }
+
+	optional := true
+	stepEnv := []harness.EnvVar{
+		{Name: "TLS", Value: "true"},
+		{Name: "PASSWORD", ValueFrom: &harness.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"}, Key: "password",
+		}}},
+		{Name: "MISSING", ValueFrom: &harness.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "password", Optional: &optional,
+		}}},
+	}
+	commands := []harness.Command{
+		{Command: "first", Env: []harness.EnvVar{
+			{Name: "STORAGE_CLASS", ValueFrom: &harness.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "config"}, Key: "storageClass",
+			}}},
+			{Name: "TLS", Value: "false"},
+		}},
+		{Command: "second", Env: []harness.EnvVar{{Name: "TOKEN", ValueFrom: &harness.EnvVarSource{File: "token.txt"}}}},
+	}
+
+	resolved, secrets, err := ResolveEnv(context.TODO(), getClient, "world", dir, stepEnv, commands)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hunter2"}, secrets)
+	require.Len(t, resolved, 2)
+	assert.Equal(t, []harness.EnvVar{
+		{Name: "TLS", Value: "true"},
+		{Name: "PASSWORD", Value: "hunter2"},
+		{Name: "STORAGE_CLASS", Value: "standard"},
+		{Name: "TLS", Value: "false"},
+	}, resolved[0].Env)
+	assert.Equal(t, []harness.EnvVar{
+		{Name: "TLS", Value: "true"},
+		{Name: "PASSWORD", Value: "hunter2"},
+		{Name: "TOKEN", Value: "token"},
+	}, resolved[1].Env)
+
+	// the commands are not changed
+	assert.Len(t, commands[0].Env, 2)
+	assert.NotNil(t, commands[0].Env[0].ValueFrom)
+
+	// a missing key of a required secret is an error
+	stepEnv[1].ValueFrom.SecretKeyRef.Key = "username"
+	_, _, err = ResolveEnv(context.TODO(), getClient, "world", dir, stepEnv, commands)
+	assert.EqualError(t, err, "environment variable PASSWORD: secret world/credentials has no key username")
+
+	// the client is only used for secrets and configmaps
+	noClient := func(bool) (client.Client, error) { return nil, assert.AnError }
+	resolved, _, err = ResolveEnv(context.TODO(), noClient, "world", dir, nil, commands[1:])
+	require.NoError(t, err)
+	assert.Equal(t, []harness.EnvVar{{Name: "TOKEN", Value: "token"}}, resolved[0].Env)
+}
+
+func TestRunCommandEnv(t *testing.T) {
+	cmd := harness.Command{
+		Script: `test "$TLS" = false && test "$NAMESPACE" = world`,
+		Env:    []harness.EnvVar{{Name: "TLS", Value: "false"}},
+	}
+	logger := NewTestLogger(t, "")
+	_, err := RunCommandWithOptions(context.TODO(), "world", cmd, "", logger, logger, logger, 0, "", CommandOptions{Variables: map[string]string{"TLS": "true"}})
+	assert.NoError(t, err)
+
+	cmd.Env[0].ValueFrom = &harness.EnvVarSource{File: "tls.txt"}
+	_, err = RunCommand(context.TODO(), "world", cmd, "", logger, logger, logger, 0, "")
+	assert.ErrorContains(t, err, "environment variable TLS has not been resolved")
+}
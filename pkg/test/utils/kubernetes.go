@@ -37,7 +37,6 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/apimachinery/pkg/types"
-	apijson "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apimachinery/pkg/watch"
@@ -493,6 +492,8 @@ func ConvertUnstructured(in client.Object) (client.Object, error) {
 		converted = &harness.TestAssert{}
 	case kind == "TestSuite":
 		converted = &harness.TestSuite{}
+	case kind == "TestMatrix":
+		converted = &harness.TestMatrix{}
 	default:
 		return in, nil
 	}
@@ -580,8 +581,50 @@ func LoadYAMLFromFile(path string) ([]client.Object, error) {
 	return LoadYAML(path, opened)
 }
 
+// LoadExpandedYAMLFromFile loads all objects from a YAML file after expanding the variables in it, see
+// LoadExpandedYAML. If variables is nil, nothing is expanded.
+func LoadExpandedYAMLFromFile(path string, variables map[string]string) ([]client.Object, error) {
+	opened, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer opened.Close()
+
+	return LoadExpandedYAML(path, opened, variables)
+}
+
+// LoadExpandedYAML loads all objects from a reader after expanding the variables in its contents like in the
+// arguments of commands, with env.ExpandWithMap. The kuttl objects, e.g. TestStep and TestAssert, are not expanded,
+// as their commands are expanded when they are run. If variables is nil, nothing is expanded.
+func LoadExpandedYAML(path string, r io.Reader, variables map[string]string) ([]client.Object, error) {
+	if variables == nil {
+		return LoadYAML(path, r)
+	}
+
+	return loadYAML(path, r, func(data []byte) []byte {
+		if isKuttlObject(data) {
+			return data
+		}
+		return []byte(env.ExpandWithMap(string(data), variables))
+	})
+}
+
+// isKuttlObject returns true if the YAML document data contains an object of the kuttl API group.
+func isKuttlObject(data []byte) bool {
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewBuffer(data), len(data)).Decode(&typeMeta); err != nil {
+		return false
+	}
+	return typeMeta.GroupVersionKind().Group == "kuttl.dev"
+}
+
 // LoadYAML loads all objects from a reader
 func LoadYAML(path string, r io.Reader) ([]client.Object, error) {
+	return loadYAML(path, r, nil)
+}
+
+// loadYAML loads all objects from a reader, passing each YAML document through expand first unless it is nil.
+func loadYAML(path string, r io.Reader, expand func(data []byte) []byte) ([]client.Object, error) {
 	yamlReader := yaml.NewYAMLReader(bufio.NewReader(r))
 
 	objects := []client.Object{}
@@ -594,6 +637,9 @@ func LoadYAML(path string, r io.Reader) ([]client.Object, error) {
 			}
 			return nil, fmt.Errorf("error reading yaml %s: %w", path, err)
 		}
+		if expand != nil {
+			data = expand(data)
+		}
 
 		unstructuredObj := &unstructured.Unstructured{}
 		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewBuffer(data), len(data))
@@ -863,89 +909,123 @@ func WithAnnotations(obj runtime.Object, annotations map[string]string) runtime.
 }
 
 // FakeDiscoveryClient returns a fake discovery client that is populated with some types for use in
-// unit tests.
-func FakeDiscoveryClient() discovery.DiscoveryInterface {
+// unit tests and offline test runs. The resources served by crds are added to the built-in types.
+func FakeDiscoveryClient(crds ...*apiextv1.CustomResourceDefinition) discovery.DiscoveryInterface {
+	resources := []*metav1.APIResourceList{
+		{
+			GroupVersion: corev1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "pod", Namespaced: true, Kind: "Pod"},
+				{Name: "namespace", Namespaced: false, Kind: "Namespace"},
+				{Name: "service", Namespaced: true, Kind: "Service"},
+				{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"},
+				{Name: "secrets", Namespaced: true, Kind: "Secret"},
+				{Name: "serviceaccounts", Namespaced: true, Kind: "ServiceAccount"},
+				{Name: "persistentvolumeclaims", Namespaced: true, Kind: "PersistentVolumeClaim"},
+				{Name: "persistentvolumes", Namespaced: false, Kind: "PersistentVolume"},
+				{Name: "events", Namespaced: true, Kind: "Event"},
+			},
+		},
+		{
+			GroupVersion: appsv1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "statefulset", Namespaced: true, Kind: "StatefulSet"},
+				{Name: "deployment", Namespaced: true, Kind: "Deployment"},
+				{Name: "daemonsets", Namespaced: true, Kind: "DaemonSet"},
+				{Name: "replicasets", Namespaced: true, Kind: "ReplicaSet"},
+			},
+		},
+		{
+			GroupVersion: batchv1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "job", Namespaced: true, Kind: "Job"},
+				{Name: "cronjobs", Namespaced: true, Kind: "CronJob"},
+			},
+		},
+		{
+			GroupVersion: batchv1beta1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "job", Namespaced: true, Kind: "CronJob"},
+			},
+		},
+		{
+			GroupVersion: rbacv1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "roles", Namespaced: true, Kind: "Role"},
+				{Name: "rolebindings", Namespaced: true, Kind: "RoleBinding"},
+				{Name: "clusterroles", Namespaced: false, Kind: "ClusterRole"},
+				{Name: "clusterrolebindings", Namespaced: false, Kind: "ClusterRoleBinding"},
+			},
+		},
+		{
+			GroupVersion: apiextv1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
+			},
+		},
+		{
+			GroupVersion: apiextv1beta1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
+			},
+		},
+	}
+
+	for _, crd := range crds {
+		resource := metav1.APIResource{
+			Name:       crd.Spec.Names.Plural,
+			Namespaced: crd.Spec.Scope == apiextv1.NamespaceScoped,
+			Kind:       crd.Spec.Names.Kind,
+		}
+		for _, version := range crd.Spec.Versions {
+			if !version.Served {
+				continue
+			}
+			groupVersion := schema.GroupVersion{Group: crd.Spec.Group, Version: version.Name}.String()
+			list := findAPIResourceList(resources, groupVersion)
+			if list == nil {
+				list = &metav1.APIResourceList{GroupVersion: groupVersion}
+				resources = append(resources, list)
+			}
+			list.APIResources = append(list.APIResources, resource)
+		}
+	}
+
 	return &fakediscovery.FakeDiscovery{
 		Fake: &coretesting.Fake{
-			Resources: []*metav1.APIResourceList{
-				{
-					GroupVersion: corev1.SchemeGroupVersion.String(),
-					APIResources: []metav1.APIResource{
-						{Name: "pod", Namespaced: true, Kind: "Pod"},
-						{Name: "namespace", Namespaced: false, Kind: "Namespace"},
-						{Name: "service", Namespaced: true, Kind: "Service"},
-					},
-				},
-				{
-					GroupVersion: appsv1.SchemeGroupVersion.String(),
-					APIResources: []metav1.APIResource{
-						{Name: "statefulset", Namespaced: true, Kind: "StatefulSet"},
-						{Name: "deployment", Namespaced: true, Kind: "Deployment"},
-					},
-				},
-				{
-					GroupVersion: batchv1.SchemeGroupVersion.String(),
-					APIResources: []metav1.APIResource{
-						{Name: "job", Namespaced: true, Kind: "Job"},
-					},
-				},
-				{
-					GroupVersion: batchv1beta1.SchemeGroupVersion.String(),
-					APIResources: []metav1.APIResource{
-						{Name: "job", Namespaced: true, Kind: "CronJob"},
-					},
-				},
-				{
-					GroupVersion: apiextv1.SchemeGroupVersion.String(),
-					APIResources: []metav1.APIResource{
-						{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
-					},
-				},
-				{
-					GroupVersion: apiextv1beta1.SchemeGroupVersion.String(),
-					APIResources: []metav1.APIResource{
-						{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
-					},
-				},
-			},
+			Resources: resources,
 		},
 	}
 }
 
+func findAPIResourceList(resources []*metav1.APIResourceList, groupVersion string) *metav1.APIResourceList {
+	for _, list := range resources {
+		if list.GroupVersion == groupVersion {
+			return list
+		}
+	}
+	return nil
+}
+
 // CreateOrUpdate will create obj if it does not exist and update if it it does.
 // retryonerror indicates whether we retry in case of conflict
 // Returns true if the object was updated and false if it was created.
 func CreateOrUpdate(ctx context.Context, cl client.Client, obj client.Object, retryOnError bool) (updated bool, err error) {
+	return CreateOrUpdateWithOptions(ctx, cl, obj, retryOnError, ApplyOptions{})
+}
+
+// CreateOrUpdateWithOptions is CreateOrUpdate with the strategy given in opts.
+func CreateOrUpdateWithOptions(ctx context.Context, cl client.Client, obj client.Object, retryOnError bool, opts ApplyOptions) (updated bool, err error) {
 	orig := obj.DeepCopyObject()
 
 	validators := []func(err error) bool{k8serrors.IsAlreadyExists}
 
-	if retryOnError {
+	// server-side applies are not based on a resource version, their conflicts are between field managers
+	if retryOnError && opts.strategy() != harness.ApplyStrategyServerSide {
 		validators = append(validators, k8serrors.IsConflict)
 	}
 	err = Retry(ctx, func(ctx context.Context) error {
-		expected := orig.DeepCopyObject()
-		actual := &unstructured.Unstructured{}
-		actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
-
-		err := cl.Get(ctx, ObjectKey(expected), actual)
-		if err == nil {
-			if err = PatchObject(actual, expected); err != nil {
-				return err
-			}
-
-			var expectedBytes []byte
-			expectedBytes, err = apijson.Marshal(expected)
-			if err != nil {
-				return err
-			}
-
-			err = cl.Patch(ctx, actual, client.RawPatch(types.MergePatchType, expectedBytes))
-			updated = true
-		} else if k8serrors.IsNotFound(err) {
-			err = cl.Create(ctx, obj)
-			updated = false
-		}
+		updated, err = createOrUpdateOnce(ctx, cl, orig.DeepCopyObject().(client.Object), obj, opts)
 		return err
 	}, validators...)
 	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -954,6 +1034,30 @@ func CreateOrUpdate(ctx context.Context, cl client.Client, obj client.Object, re
 	return updated, err
 }
 
+// CreateOrUpdateOnce is CreateOrUpdateWithOptions with a single attempt: errors are returned as they are, without
+// retries and without replacing the error of an expired ctx.
+func CreateOrUpdateOnce(ctx context.Context, cl client.Client, obj client.Object, opts ApplyOptions) (updated bool, err error) {
+	return createOrUpdateOnce(ctx, cl, obj.DeepCopyObject().(client.Object), obj, opts)
+}
+
+// createOrUpdateOnce updates the object matching expected, or creates obj if there is none.
+func createOrUpdateOnce(ctx context.Context, cl client.Client, expected client.Object, obj client.Object, opts ApplyOptions) (bool, error) {
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+
+	err := cl.Get(ctx, ObjectKey(expected), actual)
+	if err == nil {
+		return true, update(ctx, cl, actual, expected, opts)
+	}
+	if !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	if opts.strategy() == harness.ApplyStrategyServerSide {
+		return false, cl.Patch(ctx, obj, client.Apply, opts.patchOptions()...)
+	}
+	return false, cl.Create(ctx, obj)
+}
+
 // SetAnnotation sets the given key and value in the object's annotations, returning a copy.
 func SetAnnotation(obj *unstructured.Unstructured, key, value string) *unstructured.Unstructured {
 	obj = obj.DeepCopy()
@@ -1115,16 +1219,59 @@ func GetArgs(ctx context.Context, cmd harness.Command, namespace string, envMap
 	return builtCmd, nil
 }
 
+// CommandOptions are the per-command settings of RunCommandWithOptions which go beyond running the command.
+type CommandOptions struct {
+	// Variables are passed to the command as environment variables. If the command has an output, its variable is
+	// stored in Variables, which must not be nil then.
+	Variables map[string]string
+	// TestInfo is the metadata of the test running the command, which is exported to it. It may be nil.
+	TestInfo *TestInfo
+	// Client and DiscoveryClient read the objects which outputs are read from. They are required for such outputs.
+	Client          func(forceNew bool) (client.Client, error)
+	DiscoveryClient func() (discovery.DiscoveryInterface, error)
+}
+
 // RunCommand runs a command with args.
 // args gets split on spaces (respecting quoted strings).
 // if the command is run in the background a reference to the process is returned for later cleanup
 func RunCommand(ctx context.Context, namespace string, cmd harness.Command, cwd string, stdout io.Writer, stderr io.Writer, logger Logger, timeout int, kubeconfigOverride string) (*exec.Cmd, error) {
+	return RunCommandWithOptions(ctx, namespace, cmd, cwd, stdout, stderr, logger, timeout, kubeconfigOverride, CommandOptions{})
+}
+
+// RunCommandWithOptions is RunCommand with the options given in opts. The variables in opts are passed to the
+// command as environment variables, followed by the environment variables of the command, which must have been
+// resolved with ResolveEnv, and the metadata of the test.
+func RunCommandWithOptions(ctx context.Context, namespace string, cmd harness.Command, cwd string, stdout io.Writer, stderr io.Writer, logger Logger, timeout int, kubeconfigOverride string, opts CommandOptions) (*exec.Cmd, error) {
+	variables := opts.Variables
+
 	actualDir, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("command %q with %w", cmd.String(), err)
 	}
 
+	if err := cmd.ValidateOutput(); err != nil {
+		return nil, fmt.Errorf("command %q: %w", cmd.String(), err)
+	}
+	if cmd.Output != nil && variables == nil {
+		return nil, fmt.Errorf("command %q: output can not be captured here", cmd.String())
+	}
+	if cmd.Output != nil && cmd.Output.Object != nil && (opts.Client == nil || opts.DiscoveryClient == nil) {
+		return nil, fmt.Errorf("command %q: output object can not be read here", cmd.String())
+	}
+
 	kuttlENV := make(map[string]string)
+	for key, value := range variables {
+		kuttlENV[key] = value
+	}
+	for _, envVar := range cmd.Env {
+		if envVar.ValueFrom != nil {
+			return nil, fmt.Errorf("command %q: environment variable %s has not been resolved", cmd.String(), envVar.Name)
+		}
+		kuttlENV[envVar.Name] = envVar.Value
+	}
+	for key, value := range opts.TestInfo.Env() {
+		kuttlENV[key] = value
+	}
 	kuttlENV["NAMESPACE"] = namespace
 	kuttlENV["KUBECONFIG"] = kubeconfigPath(actualDir, kubeconfigOverride)
 	kuttlENV["PATH"] = fmt.Sprintf("%s/bin/:%s", actualDir, os.Getenv("PATH"))
@@ -1163,6 +1310,14 @@ func RunCommand(ctx context.Context, namespace string, cmd harness.Command, cwd
 		builtCmd.Stdout = stdout
 		builtCmd.Stderr = stderr
 	}
+	output := &bytes.Buffer{}
+	if cmd.Output != nil && cmd.Output.Object == nil {
+		if builtCmd.Stdout != nil {
+			builtCmd.Stdout = io.MultiWriter(builtCmd.Stdout, output)
+		} else {
+			builtCmd.Stdout = output
+		}
+	}
 	builtCmd.Env = os.Environ()
 	for key, value := range kuttlENV {
 		builtCmd.Env = append(builtCmd.Env, fmt.Sprintf("%s=%s", key, value))
@@ -1184,7 +1339,7 @@ func RunCommand(ctx context.Context, namespace string, cmd harness.Command, cwd
 
 	err = builtCmd.Wait()
 	if errors.As(err, &exerr) && cmd.IgnoreFailure {
-		return nil, nil
+		return nil, storeOutput(ctx, cmd, output.Bytes(), namespace, kuttlENV, opts, logger)
 	}
 	if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
 		return nil, fmt.Errorf("command %q exceeded %v sec timeout, %w", cmd.String(), timeout, cmdCtx.Err())
@@ -1192,7 +1347,59 @@ func RunCommand(ctx context.Context, namespace string, cmd harness.Command, cwd
 	if err != nil {
 		return nil, fmt.Errorf("command %q failed, %w", cmd.String(), err)
 	}
-	return nil, nil
+	return nil, storeOutput(ctx, cmd, output.Bytes(), namespace, kuttlENV, opts, logger)
+}
+
+// storeOutput stores the variable of the command's output, if it has one, in the variables of opts. The output is
+// read from stdout, or from the object it references, whose name and namespace are expanded with envMap.
+func storeOutput(ctx context.Context, cmd harness.Command, stdout []byte, namespace string, envMap map[string]string, opts CommandOptions, logger Logger) error {
+	if cmd.Output == nil {
+		return nil
+	}
+
+	var value string
+	var err error
+	if cmd.Output.Object != nil {
+		var obj client.Object
+		obj, value, err = readOutputObject(ctx, cmd.Output, namespace, envMap, opts)
+		if err != nil {
+			return fmt.Errorf("command %q output: %w", cmd.String(), err)
+		}
+		logger.Logf("stored value of %s in variable %s", ResourceID(obj), cmd.Output.Name)
+	} else {
+		value, err = cmd.Output.Value(stdout)
+		if err != nil {
+			return fmt.Errorf("command %q output: %w", cmd.String(), err)
+		}
+		logger.Logf("stored output of command in variable %s", cmd.Output.Name)
+	}
+	opts.Variables[cmd.Output.Name] = value
+	return nil
+}
+
+// readOutputObject fetches the object of output, in namespace if it is namespaced and sets none, and returns it with
+// the value of the output's variable.
+func readOutputObject(ctx context.Context, output *harness.CommandOutput, namespace string, envMap map[string]string, opts CommandOptions) (client.Object, string, error) {
+	cl, err := opts.Client(false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dClient, err := opts.DiscoveryClient()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ref := output.Object
+	obj := NewResource(ref.APIVersion, ref.Kind, env.ExpandWithMap(ref.Name, envMap), env.ExpandWithMap(ref.Namespace, envMap))
+	if _, _, err := Namespaced(dClient, obj, namespace); err != nil {
+		return nil, "", err
+	}
+	if err := cl.Get(ctx, ObjectKey(obj), obj); err != nil {
+		return nil, "", err
+	}
+	value, err := output.ObjectValue(obj.Object)
+	return obj, value, err
 }
 
 func kubeconfigPath(actualDir, override string) string {
@@ -1228,13 +1435,25 @@ func convertAssertCommand(assertCommands []harness.TestAssertCommand, timeout in
 
 // RunAssertCommands runs a set of commands specified as TestAssertCommand
 func RunAssertCommands(ctx context.Context, logger Logger, namespace string, commands []harness.TestAssertCommand, workdir string, timeout int, kubeconfigOverride string) ([]*exec.Cmd, error) {
-	return RunCommands(ctx, logger, namespace, convertAssertCommand(commands, timeout), workdir, timeout, kubeconfigOverride)
+	return RunAssertCommandsWithOptions(ctx, logger, namespace, commands, workdir, timeout, kubeconfigOverride, CommandOptions{})
+}
+
+// RunAssertCommandsWithOptions is RunAssertCommands with the options given in opts, see RunCommandsWithOptions.
+func RunAssertCommandsWithOptions(ctx context.Context, logger Logger, namespace string, commands []harness.TestAssertCommand, workdir string, timeout int, kubeconfigOverride string, opts CommandOptions) ([]*exec.Cmd, error) {
+	return RunCommandsWithOptions(ctx, logger, namespace, convertAssertCommand(commands, timeout), workdir, timeout, kubeconfigOverride, opts)
 }
 
 // RunCommands runs a set of commands, returning any errors.
 // If any (non-background) command fails, the following commands are skipped
 // commands running in the background are returned
 func RunCommands(ctx context.Context, logger Logger, namespace string, commands []harness.Command, workdir string, timeout int, kubeconfigOverride string) ([]*exec.Cmd, error) {
+	return RunCommandsWithOptions(ctx, logger, namespace, commands, workdir, timeout, kubeconfigOverride, CommandOptions{})
+}
+
+// RunCommandsWithOptions is RunCommands with the options given in opts, which are passed to every command, see
+// RunCommandWithOptions. The variables of command outputs are stored in the variables of opts, so that they are
+// passed to the following commands.
+func RunCommandsWithOptions(ctx context.Context, logger Logger, namespace string, commands []harness.Command, workdir string, timeout int, kubeconfigOverride string, opts CommandOptions) ([]*exec.Cmd, error) {
 	bgs := []*exec.Cmd{}
 
 	if commands == nil {
@@ -1242,7 +1461,7 @@ func RunCommands(ctx context.Context, logger Logger, namespace string, commands
 	}
 
 	for i, cmd := range commands {
-		bg, err := RunCommand(ctx, namespace, cmd, workdir, logger, logger, logger, timeout, kubeconfigOverride)
+		bg, err := RunCommandWithOptions(ctx, namespace, cmd, workdir, logger, logger, logger, timeout, kubeconfigOverride, opts)
 		if err != nil {
 			cmdListSize := len(commands)
 			if i+1 < cmdListSize {
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/watch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -78,6 +79,37 @@ func TestCreateOrUpdate(t *testing.T) {
 	}
 }
 
+func TestCreateOrUpdateServerSide(t *testing.T) {
+	opts := ApplyOptions{Strategy: harness.ApplyStrategyServerSide}
+
+	configMap := NewResource("v1", "ConfigMap", "server-side", "default")
+	configMap.Object["data"] = map[string]interface{}{"a": "1", "b": "2"}
+
+	updated, err := CreateOrUpdateWithOptions(context.TODO(), testenv.Client, configMap.DeepCopy(), true, opts)
+	assert.Nil(t, err)
+	assert.False(t, updated)
+
+	// another field manager takes ownership of b
+	other := NewResource("v1", "ConfigMap", "server-side", "default")
+	other.Object["data"] = map[string]interface{}{"b": "3"}
+	_, err = CreateOrUpdateWithOptions(context.TODO(), testenv.Client, other, true, ApplyOptions{Strategy: harness.ApplyStrategyServerSide, FieldManager: "other", ForceConflicts: true})
+	assert.Nil(t, err)
+
+	// changing b conflicts unless forced, and removing a from the applied configuration removes the field
+	configMap.Object["data"] = map[string]interface{}{"b": "4"}
+	_, err = CreateOrUpdateWithOptions(context.TODO(), testenv.Client, configMap.DeepCopy(), true, opts)
+	assert.True(t, k8serrors.IsConflict(err))
+
+	opts.ForceConflicts = true
+	updated, err = CreateOrUpdateWithOptions(context.TODO(), testenv.Client, configMap.DeepCopy(), true, opts)
+	assert.Nil(t, err)
+	assert.True(t, updated)
+
+	actual := NewResource("v1", "ConfigMap", "server-side", "default")
+	assert.Nil(t, testenv.Client.Get(context.TODO(), ObjectKey(actual), actual))
+	assert.Equal(t, map[string]interface{}{"b": "4"}, actual.Object["data"])
+}
+
 func TestClientWatch(t *testing.T) {
 	pod := WithSpec(t, NewPod("my-pod", "default"), map[string]interface{}{
 		"containers": []map[string]interface{}{
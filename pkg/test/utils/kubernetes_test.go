@@ -9,12 +9,15 @@ import (
 	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 
 	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
 )
@@ -516,6 +519,53 @@ func TestRunScript(t *testing.T) {
 	}
 }
 
+func TestRunCommandsOutput(t *testing.T) {
+	variables := map[string]string{"GREETING": "hello"}
+	commands := []harness.Command{
+		{Script: "echo $GREETING world", Output: &harness.CommandOutput{Name: "MESSAGE"}},
+		{Script: `echo '{"items": [{"name": "first"}, {"name": "second"}]}'`, Output: &harness.CommandOutput{Name: "SECOND", JSONPath: ".items[1].name"}},
+		{Script: `test "$MESSAGE $SECOND" = "hello world second"`},
+	}
+
+	_, err := RunCommandsWithOptions(context.TODO(), NewTestLogger(t, ""), "", commands, "", 0, "", CommandOptions{Variables: variables})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"GREETING": "hello", "MESSAGE": "hello world", "SECOND": "second"}, variables)
+
+	_, err = RunCommands(context.TODO(), NewTestLogger(t, ""), "", commands[:1], "", 0, "")
+	assert.ErrorContains(t, err, "output can not be captured here")
+}
+
+func TestRunCommandsOutputObject(t *testing.T) {
+	pod := NewPod("zookeeper-0", "world")
+	pod.Object["status"] = map[string]interface{}{"podIP": "10.0.0.1"}
+	cl := fake.NewClientBuilder().WithScheme(Scheme()).WithObjects(pod, NewResource("v1", "Namespace", "world", "")).Build()
+	getClient := func(bool) (client.Client, error) { return cl, nil }
+	getDiscoveryClient := func() (discovery.DiscoveryInterface, error) { return FakeDiscoveryClient(), nil }
+
+	variables := map[string]string{"RELEASE": "zookeeper"}
+	commands := []harness.Command{
+		{Command: "true", Output: &harness.CommandOutput{Name: "POD_IP", JSONPath: ".status.podIP", Object: &harness.OutputObjectReference{
+			APIVersion: "v1", Kind: "Pod", Name: "$RELEASE-0",
+		}}},
+		// cluster-scoped objects are read without a namespace
+		{Command: "true", Output: &harness.CommandOutput{Name: "NAMESPACE_NAME", JSONPath: ".metadata.name", Object: &harness.OutputObjectReference{
+			APIVersion: "v1", Kind: "Namespace", Name: "$NAMESPACE",
+		}}},
+	}
+
+	_, err := RunCommandsWithOptions(context.TODO(), NewTestLogger(t, ""), "world", commands, "", 0, "", CommandOptions{Variables: variables, Client: getClient, DiscoveryClient: getDiscoveryClient})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"RELEASE": "zookeeper", "POD_IP": "10.0.0.1", "NAMESPACE_NAME": "world"}, variables)
+
+	// a missing object is an error
+	commands[0].Output.Object.Name = "zookeeper-1"
+	_, err = RunCommandsWithOptions(context.TODO(), NewTestLogger(t, ""), "world", commands[:1], "", 0, "", CommandOptions{Variables: variables, Client: getClient, DiscoveryClient: getDiscoveryClient})
+	assert.ErrorContains(t, err, `pods "zookeeper-1" not found`)
+
+	_, err = RunCommandsWithOptions(context.TODO(), NewTestLogger(t, ""), "world", commands[:1], "", 0, "", CommandOptions{Variables: variables})
+	assert.ErrorContains(t, err, "output object can not be read here")
+}
+
 func TestPrettyDiff(t *testing.T) {
 	actual, err := LoadYAMLFromFile("test_data/prettydiff-actual.yaml")
 	assert.NoError(t, err)
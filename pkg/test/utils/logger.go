@@ -3,8 +3,12 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Logger is an interface used by the KUTTL test operator to provide logging of tests.
@@ -73,3 +77,117 @@ func (t *TestLogger) Flush() {
 		t.buffer = []byte{}
 	}
 }
+
+// secretMask replaces secret values in logs.
+const secretMask = "******"
+
+// minSecretLength is the length below which secret values are only masked where they are a whole word, as masking
+// every occurrence of a few characters would make the logs useless.
+const minSecretLength = 4
+
+// maskingLogger is a Logger which replaces secret values in everything logged with secretMask.
+type maskingLogger struct {
+	logger  Logger
+	secrets []string
+	buffer  []byte
+}
+
+// NewMaskingLogger returns a Logger which logs to logger, replacing the secrets in everything logged. The lines of
+// multi-line secrets are masked separately, as the output of commands is logged line by line. Values shorter than
+// minSecretLength are only masked where they are not part of a longer word. If there are no secrets, logger is
+// returned.
+func NewMaskingLogger(logger Logger, secrets []string) Logger {
+	masked := []string{}
+	for _, secret := range secrets {
+		for _, line := range strings.Split(secret, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				masked = append(masked, line)
+			}
+		}
+	}
+	if len(masked) == 0 {
+		return logger
+	}
+
+	// mask longer secrets first, so that secrets containing others are not partially masked
+	sort.Slice(masked, func(i, j int) bool { return len(masked[i]) > len(masked[j]) })
+	return &maskingLogger{logger: logger, secrets: masked}
+}
+
+func (m *maskingLogger) mask(s string) string {
+	for _, secret := range m.secrets {
+		if len(secret) < minSecretLength {
+			s = maskWord(s, secret)
+		} else {
+			s = strings.ReplaceAll(s, secret, secretMask)
+		}
+	}
+	return s
+}
+
+// maskWord replaces the occurrences of secret in s which are not preceded or followed by a letter or digit.
+func maskWord(s, secret string) string {
+	var masked strings.Builder
+	start := 0
+	for {
+		i := strings.Index(s[start:], secret)
+		if i < 0 {
+			break
+		}
+		i += start
+		end := i + len(secret)
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		masked.WriteString(s[start:i])
+		if isWordRune(before) || isWordRune(after) {
+			masked.WriteString(secret)
+		} else {
+			masked.WriteString(secretMask)
+		}
+		start = end
+	}
+	masked.WriteString(s[start:])
+	return masked.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Log logs the provided arguments with the secrets masked.
+func (m *maskingLogger) Log(args ...interface{}) {
+	m.logger.Log(m.mask(strings.TrimSuffix(fmt.Sprintln(args...), "\n")))
+}
+
+// Logf logs the provided arguments with the secrets masked.
+func (m *maskingLogger) Logf(format string, args ...interface{}) {
+	m.logger.Log(m.mask(fmt.Sprintf(format, args...)))
+}
+
+// WithPrefix returns a new masking logger with the provided prefix appended to the current prefix.
+func (m *maskingLogger) WithPrefix(prefix string) Logger {
+	return &maskingLogger{logger: m.logger.WithPrefix(prefix), secrets: m.secrets}
+}
+
+// Write implements the io.Writer interface. Like TestLogger, it logs each line written to it and buffers incomplete
+// lines, so that secrets are not split between writes.
+func (m *maskingLogger) Write(p []byte) (n int, err error) {
+	m.buffer = append(m.buffer, p...)
+
+	splitBuf := bytes.Split(m.buffer, []byte{'\n'})
+	m.buffer = splitBuf[len(splitBuf)-1]
+
+	for _, line := range splitBuf[:len(splitBuf)-1] {
+		m.Log(string(line))
+	}
+
+	return len(p), nil
+}
+
+func (m *maskingLogger) Flush() {
+	if len(m.buffer) != 0 {
+		m.Log(string(m.buffer))
+		m.buffer = []byte{}
+	}
+	m.logger.Flush()
+}
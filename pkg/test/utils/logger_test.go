@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger records the lines logged to it.
+type recordingLogger struct {
+	lines []string
+}
+
+func (r *recordingLogger) Log(args ...interface{}) { r.lines = append(r.lines, fmt.Sprint(args...)) }
+func (r *recordingLogger) Logf(format string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+func (r *recordingLogger) WithPrefix(string) Logger          { return r }
+func (r *recordingLogger) Write(p []byte) (n int, err error) { return len(p), nil }
+func (r *recordingLogger) Flush()                            {}
+
+func TestMaskingLogger(t *testing.T) {
+	recorder := &recordingLogger{}
+	assert.Same(t, recorder, NewMaskingLogger(recorder, []string{""}))
+
+	logger := NewMaskingLogger(recorder, []string{"hunter2", "-----BEGIN KEY-----\nabc123\n-----END KEY-----\n"})
+	logger.Log("password", "hunter2")
+	logger.Logf("running command: %v", []string{"login", "--password=hunter2"})
+
+	// output written in chunks is masked line by line
+	_, _ = logger.Write([]byte("password: hun"))
+	_, _ = logger.Write([]byte("ter2\nkey:\n-----BEGIN KEY-----\nabc"))
+	_, _ = logger.Write([]byte("123\n-----END KEY-----"))
+	logger.Flush()
+
+	assert.Equal(t, []string{
+		"password ******",
+		"running command: [login --password=******]",
+		"password: ******",
+		"key:",
+		"******",
+		"******",
+		"******",
+	}, recorder.lines)
+}
+
+func TestMaskingLoggerShortSecrets(t *testing.T) {
+	recorder := &recordingLogger{}
+	logger := NewMaskingLogger(recorder, []string{"42", "abc", "hunter2"})
+	logger.Log("pin: 42, 4242 and 142")
+	logger.Log("token=abc abcdef (abc)")
+
+	assert.Equal(t, []string{
+		"pin: ******, 4242 and 142",
+		"token=****** abcdef (******)",
+	}, recorder.lines)
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matcherRegex recognises value matchers in expected leaf values, e.g. "(regex ^v1\.)" or "(>= 2)".
+var matcherRegex = regexp.MustCompile(`^\((regex|type|exists|absent|>=|<=|>|<|!=)(?:\s+(.*?))?\s*\)$`)
+
+// valueMatcher is a matcher parsed from an expected leaf value, which is used in place of an equality comparison.
+type valueMatcher struct {
+	name string
+	arg  string
+
+	regex  *regexp.Regexp
+	number float64
+}
+
+// parseMatcher parses expected into a valueMatcher if it is a string of the form "(name arg)".
+// The second return value is false if expected is not a matcher.
+// A leading backslash escapes a matcher, so that it is compared literally.
+func parseMatcher(expected interface{}) (*valueMatcher, bool, error) {
+	s, ok := expected.(string)
+	if !ok {
+		return nil, false, nil
+	}
+
+	matches := matcherRegex.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, false, nil
+	}
+
+	m := &valueMatcher{name: matches[1], arg: matches[2]}
+
+	var err error
+	switch m.name {
+	case "exists", "absent":
+		if m.arg != "" {
+			err = fmt.Errorf("matcher %q takes no argument", m.name)
+		}
+	case "regex":
+		m.regex, err = regexp.Compile(m.arg)
+	case "type":
+		switch m.arg {
+		case "string", "number", "integer", "boolean", "object", "array", "null":
+		default:
+			err = fmt.Errorf("unknown type %q, expected one of string, number, integer, boolean, object, array or null", m.arg)
+		}
+	default:
+		m.number, err = strconv.ParseFloat(m.arg, 64)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid matcher %s: %w", s, err)
+	}
+
+	return m, true, nil
+}
+
+// unescapeMatcher removes the backslash from an escaped matcher, e.g. "\(exists)" becomes "(exists)".
+func unescapeMatcher(expected interface{}) (interface{}, bool) {
+	s, ok := expected.(string)
+	if !ok || !strings.HasPrefix(s, `\`) || !matcherRegex.MatchString(s[1:]) {
+		return expected, false
+	}
+	return s[1:], true
+}
+
+// allowsMissing returns true if the matcher is satisfied by an absent map key.
+func (m *valueMatcher) allowsMissing() bool {
+	return m.name == "absent"
+}
+
+// match checks actual against the matcher.
+func (m *valueMatcher) match(actual interface{}) error {
+	switch m.name {
+	case "exists":
+		return nil
+	case "absent":
+		return fmt.Errorf("key must be absent, but has value: %v", actual)
+	case "regex":
+		s, ok := actual.(string)
+		if !ok {
+			return fmt.Errorf("regex %q can only match strings, actual: %v (%T)", m.arg, actual, actual)
+		}
+		if !m.regex.MatchString(s) {
+			return fmt.Errorf("value %q does not match regex %q", s, m.arg)
+		}
+		return nil
+	case "type":
+		if actualType := jsonType(actual); actualType != m.arg && !(m.arg == "number" && actualType == "integer") {
+			return fmt.Errorf("type mismatch, expected: %s != actual: %s", m.arg, actualType)
+		}
+		return nil
+	}
+
+	n, err := toFloat(actual)
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	switch m.name {
+	case ">":
+		ok = n > m.number
+	case ">=":
+		ok = n >= m.number
+	case "<":
+		ok = n < m.number
+	case "<=":
+		ok = n <= m.number
+	case "!=":
+		ok = n != m.number
+	}
+	if !ok {
+		return fmt.Errorf("value mismatch, expected: %s %s != actual: %v", m.name, m.arg, actual)
+	}
+	return nil
+}
+
+// jsonType returns the JSON type name of an unstructured value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int32, int64:
+		return "integer"
+	case float32, float64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// toFloat converts a numeric unstructured value to a float64.
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	}
+	return 0, fmt.Errorf("numeric comparison requires a number, actual: %v (%T)", v, v)
+}
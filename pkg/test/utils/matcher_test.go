@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSubsetMatchers(t *testing.T) {
+	actual := map[string]interface{}{
+		"version":  "v1.2.3",
+		"replicas": int64(3),
+		"ratio":    0.5,
+		"ready":    true,
+		"empty":    "",
+		"spec":     map[string]interface{}{"paused": false},
+		"literal":  "(exists)",
+	}
+
+	for _, tt := range []struct {
+		name     string
+		expected map[string]interface{}
+		err      string
+	}{
+		{name: "regex", expected: map[string]interface{}{"version": `(regex ^v1\.)`}},
+		{name: "regex mismatch", expected: map[string]interface{}{"version": `(regex ^v2\.)`}, err: `.version: value "v1.2.3" does not match regex "^v2\\."`},
+		{name: "non-empty string", expected: map[string]interface{}{"empty": "(regex .+)"}, err: `.empty: value "" does not match regex ".+"`},
+		{name: "regex on number", expected: map[string]interface{}{"replicas": "(regex 3)"}, err: `.replicas: regex "3" can only match strings, actual: 3 (int64)`},
+		{name: "invalid regex", expected: map[string]interface{}{"version": "(regex [)"}, err: ".version: invalid matcher (regex [): error parsing regexp: missing closing ]: `[`"},
+		{name: "greater or equal", expected: map[string]interface{}{"replicas": "(>= 2)"}},
+		{name: "greater or equal boundary", expected: map[string]interface{}{"replicas": "(>= 3)"}},
+		{name: "greater", expected: map[string]interface{}{"replicas": "(> 3)"}, err: ".replicas: value mismatch, expected: > 3 != actual: 3"},
+		{name: "less than float", expected: map[string]interface{}{"ratio": "(< 1)"}},
+		{name: "less or equal", expected: map[string]interface{}{"replicas": "(<= 2.5)"}, err: ".replicas: value mismatch, expected: <= 2.5 != actual: 3"},
+		{name: "not equal", expected: map[string]interface{}{"replicas": "(!= 0)"}},
+		{name: "numeric comparison of string", expected: map[string]interface{}{"version": "(> 1)"}, err: ".version: numeric comparison requires a number, actual: v1.2.3 (string)"},
+		{name: "invalid number", expected: map[string]interface{}{"replicas": "(> two)"}, err: `.replicas: invalid matcher (> two): strconv.ParseFloat: parsing "two": invalid syntax`},
+		{name: "type string", expected: map[string]interface{}{"version": "(type string)"}},
+		{name: "type integer is a number", expected: map[string]interface{}{"replicas": "(type number)"}},
+		{name: "type object", expected: map[string]interface{}{"spec": "(type object)"}},
+		{name: "type mismatch", expected: map[string]interface{}{"ready": "(type string)"}, err: ".ready: type mismatch, expected: string != actual: boolean"},
+		{name: "exists", expected: map[string]interface{}{"ready": "(exists)"}},
+		{name: "exists on missing key", expected: map[string]interface{}{"missing": "(exists)"}, err: ".missing: key is missing from map"},
+		{name: "absent", expected: map[string]interface{}{"missing": "(absent)"}},
+		{name: "absent nested", expected: map[string]interface{}{"spec": map[string]interface{}{"replicas": "(absent)"}}},
+		{name: "absent but present", expected: map[string]interface{}{"ready": "(absent)"}, err: ".ready: key must be absent, but has value: true"},
+		{name: "escaped matcher", expected: map[string]interface{}{"literal": `\(exists)`}},
+		{name: "escaped matcher mismatch", expected: map[string]interface{}{"version": `\(exists)`}, err: ".version: value mismatch, expected: (exists) != actual: v1.2.3"},
+		{name: "unknown matcher is a literal", expected: map[string]interface{}{"version": "(semver 1)"}, err: ".version: value mismatch, expected: (semver 1) != actual: v1.2.3"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := IsSubset(tt.expected, actual)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
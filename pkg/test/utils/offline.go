@@ -0,0 +1,131 @@
+package utils
+
+// Contains an in-memory stand-in for a Kubernetes cluster, used to run test steps without a cluster.
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	coretesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// OfflineCluster is an in-memory object store which stands in for a Kubernetes cluster. Objects are stored
+// as they are applied: there are no controllers, admission or validation, and server-side apply is not supported.
+// Its methods match the client accessors of Case and Step.
+type OfflineCluster struct {
+	client     client.Client
+	discovery  discovery.DiscoveryInterface
+	kubernetes kubernetes.Interface
+}
+
+// NewOfflineCluster creates an empty OfflineCluster which serves the built-in types of FakeDiscoveryClient and
+// the custom resources defined by crds.
+func NewOfflineCluster(crds ...*apiextv1.CustomResourceDefinition) *OfflineCluster {
+	tracker := coretesting.NewObjectTracker(Scheme(), scheme.Codecs.UniversalDecoder())
+
+	// custom resources with a status subresource must not have their status changed by updates and patches
+	withStatus := []client.Object{}
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			if version.Subresources == nil || version.Subresources.Status == nil {
+				continue
+			}
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind})
+			withStatus = append(withStatus, obj)
+		}
+	}
+
+	// the clientset shares the object store of the client, so that e.g. pods applied by a step can be read
+	kubernetesClient := kfake.NewSimpleClientset()
+	kubernetesClient.PrependReactor("*", "*", coretesting.ObjectReaction(tracker))
+
+	return &OfflineCluster{
+		client: fake.NewClientBuilder().
+			WithScheme(Scheme()).
+			WithObjectTracker(tracker).
+			WithStatusSubresource(withStatus...).
+			Build(),
+		discovery:  FakeDiscoveryClient(crds...),
+		kubernetes: kubernetesClient,
+	}
+}
+
+// Client returns the client of the cluster. All calls return the same client.
+func (o *OfflineCluster) Client(bool) (client.Client, error) {
+	return o.client, nil
+}
+
+// DiscoveryClient returns the discovery client of the cluster.
+func (o *OfflineCluster) DiscoveryClient() (discovery.DiscoveryInterface, error) {
+	return o.discovery, nil
+}
+
+// KubernetesClient returns the clientset of the cluster.
+func (o *OfflineCluster) KubernetesClient() (kubernetes.Interface, error) {
+	return o.kubernetes, nil
+}
+
+// LoadCRDs loads all apiextensions.k8s.io/v1 CustomResourceDefinitions from the YAML manifests in crdDir.
+// Other objects are skipped.
+func LoadCRDs(crdDir string) ([]*apiextv1.CustomResourceDefinition, error) {
+	crds := []*apiextv1.CustomResourceDefinition{}
+
+	if crdDir == "" {
+		return crds, nil
+	}
+
+	crdKind := NewResource("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "")
+
+	err := filepath.Walk(crdDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		extensions := map[string]bool{
+			".yaml": true,
+			".yml":  true,
+			".json": true,
+		}
+		if info.IsDir() || !extensions[filepath.Ext(path)] {
+			return nil
+		}
+
+		objs, err := LoadYAMLFromFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range objs {
+			if !MatchesKind(obj, crdKind) {
+				log.Printf("Skipping resource %s because it is not a %s", obj.GetObjectKind().GroupVersionKind().String(), crdKind.GroupVersionKind().String())
+				continue
+			}
+
+			unstruct, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+			if err != nil {
+				return fmt.Errorf("error converting %s (%s): %w", ResourceID(obj), path, err)
+			}
+			crd := &apiextv1.CustomResourceDefinition{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstruct, crd); err != nil {
+				return fmt.Errorf("error converting %s (%s): %w", ResourceID(obj), path, err)
+			}
+			crds = append(crds, crd)
+		}
+
+		return nil
+	})
+	return crds, err
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const offlineTestCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: zookeeperclusters.zookeeper.stackable.tech
+spec:
+  group: zookeeper.stackable.tech
+  names:
+    kind: ZookeeperCluster
+    plural: zookeeperclusters
+  scope: Namespaced
+  versions:
+    - name: v1alpha1
+      served: true
+      storage: true
+      subresources:
+        status: {}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+
+func TestLoadCRDs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "crds.yaml"), []byte(offlineTestCRD), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a manifest"), 0600))
+
+	crds, err := LoadCRDs(dir)
+	require.NoError(t, err)
+	require.Len(t, crds, 1)
+	assert.Equal(t, "zookeeperclusters.zookeeper.stackable.tech", crds[0].Name)
+	assert.Equal(t, "ZookeeperCluster", crds[0].Spec.Names.Kind)
+
+	crds, err = LoadCRDs("")
+	require.NoError(t, err)
+	assert.Empty(t, crds)
+}
+
+func TestOfflineCluster(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "crds.yaml"), []byte(offlineTestCRD), 0600))
+	crds, err := LoadCRDs(dir)
+	require.NoError(t, err)
+
+	offline := NewOfflineCluster(crds...)
+	cl, err := offline.Client(false)
+	require.NoError(t, err)
+	dClient, err := offline.DiscoveryClient()
+	require.NoError(t, err)
+	kClient, err := offline.KubernetesClient()
+	require.NoError(t, err)
+
+	// custom resources are namespaced according to their CRD
+	zk := NewResource("zookeeper.stackable.tech/v1alpha1", "ZookeeperCluster", "simple", "")
+	zk.Object["status"] = map[string]interface{}{"ready": true}
+	_, namespace, err := Namespaced(dClient, zk, "world")
+	require.NoError(t, err)
+	assert.Equal(t, "world", namespace)
+
+	_, err = CreateOrUpdate(context.TODO(), cl, zk, true)
+	require.NoError(t, err)
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(schema.GroupVersionKind{Group: "zookeeper.stackable.tech", Version: "v1alpha1", Kind: "ZookeeperCluster"})
+	require.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: "world", Name: "simple"}, actual))
+
+	// updates do not change the status of custom resources with a status subresource
+	update := NewResource("zookeeper.stackable.tech/v1alpha1", "ZookeeperCluster", "simple", "world")
+	update.Object["status"] = map[string]interface{}{"ready": false}
+	_, err = CreateOrUpdate(context.TODO(), cl, update, true)
+	require.NoError(t, err)
+	require.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: "world", Name: "simple"}, actual))
+	ready, _, _ := unstructured.NestedBool(actual.Object, "status", "ready")
+	assert.True(t, ready)
+
+	// the clientset reads the objects written by the client
+	require.NoError(t, cl.Create(context.TODO(), NewPod("hello", "world")))
+	pod, err := kClient.CoreV1().Pods("world").Get(context.TODO(), "hello", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", pod.Name)
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+// ExpectedRejection describes how the API server is expected to reject an applied object.
+// A zero Code and a nil Message match any rejection.
+type ExpectedRejection struct {
+	Code    int32
+	Message *regexp.Regexp
+}
+
+// ExpectedRejectionFromAnnotations builds the ExpectedRejection configured by the kuttl.dev/ annotations of an
+// applied object. It returns nil if the object is expected to be accepted.
+func ExpectedRejectionFromAnnotations(annotations map[string]string) (*ExpectedRejection, error) {
+	rejection := &ExpectedRejection{}
+	expected := false
+
+	if value, ok := annotations[harness.ExpectRejectionAnnotation]; ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s has invalid value %q, expected true or false", harness.ExpectRejectionAnnotation, value)
+		}
+		expected = b
+	}
+	if value, ok := annotations[harness.ExpectRejectionCodeAnnotation]; ok {
+		code, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("annotation %s has invalid value %q, expected an HTTP status code", harness.ExpectRejectionCodeAnnotation, value)
+		}
+		rejection.Code = int32(code)
+		expected = true
+	}
+	if value, ok := annotations[harness.ExpectRejectionMessageAnnotation]; ok {
+		message, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s has invalid regex %q: %w", harness.ExpectRejectionMessageAnnotation, value, err)
+		}
+		rejection.Message = message
+		expected = true
+	}
+
+	if !expected {
+		return nil, nil
+	}
+	return rejection, nil
+}
+
+// Check returns an error unless err, the result of applying the object, is a matching rejection. Only errors
+// returned by the API server are rejections, other errors like connection failures and timeouts are not.
+func (r *ExpectedRejection) Check(err error) error {
+	if err == nil {
+		return errors.New("expected to be rejected, but was accepted")
+	}
+
+	var status k8serrors.APIStatus
+	if !errors.As(err, &status) {
+		return fmt.Errorf("expected to be rejected by the API server, got: %w", err)
+	}
+	if r.Code != 0 {
+		if code := status.Status().Code; code != r.Code {
+			return fmt.Errorf("expected rejection with status code %d, got status code %d: %w", r.Code, code, err)
+		}
+	}
+	if r.Message != nil && !r.Message.MatchString(err.Error()) {
+		return fmt.Errorf("expected rejection message to match %q, got: %w", r.Message.String(), err)
+	}
+	return nil
+}
+
+// WithoutKuttlAnnotations returns a copy of obj without the annotations which configure kuttl, so that they are not sent to
+// the API server.
+func WithoutKuttlAnnotations(obj client.Object) client.Object {
+	stripped := obj.DeepCopyObject().(client.Object)
+
+	annotations := stripped.GetAnnotations()
+	for key := range annotations {
+		if kuttlAnnotationKeys[key] {
+			delete(annotations, key)
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	stripped.SetAnnotations(annotations)
+	return stripped
+}
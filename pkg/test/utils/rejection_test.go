@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+)
+
+func TestExpectedRejectionFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+		code        int32
+		message     string
+		err         string
+	}{
+		{name: "none", annotations: map[string]string{"kuttl.dev/list-matching": "Unordered"}},
+		{name: "true", annotations: map[string]string{harness.ExpectRejectionAnnotation: "true"}, expected: true},
+		{name: "false", annotations: map[string]string{harness.ExpectRejectionAnnotation: "false"}},
+		{name: "code", annotations: map[string]string{harness.ExpectRejectionCodeAnnotation: "422"}, expected: true, code: 422},
+		{name: "message", annotations: map[string]string{harness.ExpectRejectionMessageAnnotation: "denied"}, expected: true, message: "denied"},
+		{name: "invalid value", annotations: map[string]string{harness.ExpectRejectionAnnotation: "yes"}, err: `annotation kuttl.dev/expect-rejection has invalid value "yes", expected true or false`},
+		{name: "invalid code", annotations: map[string]string{harness.ExpectRejectionCodeAnnotation: "42"}, err: `annotation kuttl.dev/expect-rejection-code has invalid value "42", expected an HTTP status code`},
+		{name: "invalid message", annotations: map[string]string{harness.ExpectRejectionMessageAnnotation: "("}, err: "annotation kuttl.dev/expect-rejection-message has invalid regex \"(\": error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rejection, err := ExpectedRejectionFromAnnotations(tt.annotations)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			if !tt.expected {
+				assert.Nil(t, rejection)
+				return
+			}
+			require.NotNil(t, rejection)
+			assert.Equal(t, tt.code, rejection.Code)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, rejection.Message.String())
+			} else {
+				assert.Nil(t, rejection.Message)
+			}
+		})
+	}
+}
+
+func TestExpectedRejectionCheck(t *testing.T) {
+	forbidden := k8serrors.NewForbidden(schema.GroupResource{Resource: "zookeeperclusters"}, "simple-zk", errors.New("replicas must be odd"))
+
+	assert.NoError(t, (&ExpectedRejection{}).Check(forbidden))
+	assert.NoError(t, (&ExpectedRejection{Code: 403}).Check(forbidden))
+	assert.EqualError(t, (&ExpectedRejection{}).Check(nil), "expected to be rejected, but was accepted")
+	assert.EqualError(t, (&ExpectedRejection{Code: 422}).Check(forbidden),
+		`expected rejection with status code 422, got status code 403: zookeeperclusters "simple-zk" is forbidden: replicas must be odd`)
+	assert.EqualError(t, (&ExpectedRejection{Code: 422}).Check(errors.New("connection refused")),
+		"expected to be rejected by the API server, got: connection refused")
+
+	// errors which are not returned by the API server never match, not even any rejection
+	assert.EqualError(t, (&ExpectedRejection{}).Check(errors.New("connection reset by peer")),
+		"expected to be rejected by the API server, got: connection reset by peer")
+	assert.EqualError(t, (&ExpectedRejection{Message: regexp.MustCompile("timeout")}).Check(context.DeadlineExceeded),
+		"expected to be rejected by the API server, got: context deadline exceeded")
+}
+
+func TestWithoutKuttlAnnotations(t *testing.T) {
+	obj := WithAnnotations(NewPod("hello", ""), map[string]string{
+		harness.ExpectRejectionAnnotation: "true",
+		"kuttl.dev/owner":                 "zookeeper",
+	}).(client.Object)
+
+	stripped := WithoutKuttlAnnotations(obj)
+	assert.Equal(t, map[string]string{"kuttl.dev/owner": "zookeeper"}, stripped.GetAnnotations())
+	// the original object is left untouched
+	assert.Len(t, obj.GetAnnotations(), 2)
+
+	stripped = WithoutKuttlAnnotations(WithAnnotations(NewPod("hello", ""), map[string]string{harness.ExpectRejectionAnnotation: "true"}).(client.Object))
+	assert.Nil(t, stripped.GetAnnotations())
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// quantityFields are the fields whose values are maps of resource quantities, e.g. the limits of a container or the
+// capacity of a node.
+var quantityFields = map[string]bool{
+	"limits":      true,
+	"requests":    true,
+	"capacity":    true,
+	"allocatable": true,
+	"hard":        true,
+	"used":        true,
+	"overhead":    true,
+}
+
+// quantitySuffix matches quantities with a unit, e.g. "1Gi" or "500m", but not plain numbers such as "1e3".
+var quantitySuffix = regexp.MustCompile(`^[+-]?[0-9.]+(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)$`)
+
+// semanticEqual compares two leaf values at path by their meaning to Kubernetes: as RFC 3339 timestamps if both are
+// timestamps, as resource quantities if path is a resource quantity field and both are quantities, as durations such
+// as "1m30s" if both are durations, and otherwise as resource quantities if both are quantities and one of the values
+// has a unit. The second return value is false if the values are none of these, in which case they have to be
+// compared as they are, so that e.g. versions like "3.10" and "3.1" do not match.
+func semanticEqual(expected, actual interface{}, path string) (bool, bool) {
+	if expectedTime, ok := toTime(expected); ok {
+		if actualTime, ok := toTime(actual); ok {
+			return expectedTime.Equal(actualTime), true
+		}
+	}
+
+	quantityPath := isQuantityPath(path)
+	if !quantityPath {
+		if expectedDuration, ok := toDuration(expected); ok {
+			if actualDuration, ok := toDuration(actual); ok {
+				return expectedDuration == actualDuration, true
+			}
+		}
+	}
+
+	if !quantityPath && !hasQuantitySuffix(expected) && !hasQuantitySuffix(actual) {
+		return false, false
+	}
+	if expectedQuantity, ok := toQuantity(expected); ok {
+		if actualQuantity, ok := toQuantity(actual); ok {
+			return expectedQuantity.Cmp(actualQuantity) == 0, true
+		}
+	}
+
+	return false, false
+}
+
+// isQuantityPath returns true if path is a resource quantity field, e.g. ".spec.containers.resources.limits.cpu"
+// or ".spec.volumes.emptyDir.sizeLimit".
+func isQuantityPath(path string) bool {
+	keys := strings.Split(path, ".")
+	if keys[len(keys)-1] == "sizeLimit" {
+		return true
+	}
+	return len(keys) >= 2 && quantityFields[keys[len(keys)-2]]
+}
+
+func hasQuantitySuffix(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && quantitySuffix.MatchString(s)
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+// toDuration parses v as a duration like time.ParseDuration, but only if it has a unit, so that plain numbers such
+// as "0" are not durations.
+func toDuration(v interface{}) (time.Duration, bool) {
+	s, ok := v.(string)
+	if !ok || !strings.ContainsAny(s, "nsuµmh") {
+		return 0, false
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil
+}
+
+func toQuantity(v interface{}) (resource.Quantity, bool) {
+	var s string
+	switch n := v.(type) {
+	case string:
+		s = n
+	case int:
+		s = strconv.Itoa(n)
+	case int32:
+		s = strconv.FormatInt(int64(n), 10)
+	case int64:
+		s = strconv.FormatInt(n, 10)
+	case float32:
+		s = strconv.FormatFloat(float64(n), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return resource.Quantity{}, false
+	}
+
+	q, err := resource.ParseQuantity(s)
+	return q, err == nil
+}
+
+// semanticPathMatches returns true if path is one of the paths or is nested below one of them.
+// Paths are dot-separated keys, list indices are not part of them.
+func semanticPathMatches(path string, paths []string) bool {
+	for _, p := range paths {
+		p = "." + strings.TrimPrefix(p, ".")
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
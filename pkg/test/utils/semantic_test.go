@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemanticEqualDurations(t *testing.T) {
+	for _, tt := range []struct {
+		expected, actual interface{}
+		path             string
+		equal, semantic  bool
+	}{
+		{"1h", "60m", ".spec.timeout", true, true},
+		{"90s", "1m30s", ".spec.timeout", true, true},
+		{"1h", "1h1s", ".spec.timeout", false, true},
+		// plain numbers are not durations
+		{"0", "0s", ".spec.timeout", false, false},
+		{"60", "1m", ".spec.timeout", false, true},
+		// values in resource quantity fields are quantities, where "1m" is a thousandth
+		{"1m", "0.001", ".resources.limits.cpu", true, true},
+		{"1m", "60s", ".resources.limits.cpu", false, false},
+	} {
+		equal, semantic := semanticEqual(tt.expected, tt.actual, tt.path)
+		assert.Equal(t, tt.equal, equal, "%v and %v at %s", tt.expected, tt.actual, tt.path)
+		assert.Equal(t, tt.semantic, semantic, "%v and %v at %s", tt.expected, tt.actual, tt.path)
+	}
+
+	semantic := SubsetOptions{SemanticPaths: []string{"spec.timeout"}}
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{"spec": map[string]interface{}{"timeout": "90s"}},
+		map[string]interface{}{"spec": map[string]interface{}{"timeout": "1m30s"}}, semantic))
+	assert.NotNil(t, IsSubsetWithOptions(map[string]interface{}{"spec": map[string]interface{}{"interval": "90s"}},
+		map[string]interface{}{"spec": map[string]interface{}{"interval": "1m30s"}}, semantic))
+}
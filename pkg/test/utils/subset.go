@@ -3,8 +3,50 @@ package utils
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
 )
 
+// kuttlAnnotationKeys are the annotations which configure how kuttl applies or compares an object. Other annotations,
+// also with the kuttl.dev/ prefix, are part of the object.
+var kuttlAnnotationKeys = map[string]bool{
+	harness.ListMatchingAnnotation:           true,
+	harness.SemanticComparisonAnnotation:     true,
+	harness.CountAnnotation:                  true,
+	harness.MinCountAnnotation:               true,
+	harness.MaxCountAnnotation:               true,
+	harness.ExpectRejectionAnnotation:        true,
+	harness.ExpectRejectionCodeAnnotation:    true,
+	harness.ExpectRejectionMessageAnnotation: true,
+}
+
+// SubsetOptions configures how IsSubsetWithOptions compares objects.
+type SubsetOptions struct {
+	// UnorderedLists makes every element of an expected slice match a distinct element of the actual slice,
+	// regardless of order. Additional elements in the actual slice are allowed.
+	UnorderedLists bool
+	// SemanticComparison compares leaf values which are both resource quantities, both RFC 3339 timestamps, or
+	// both durations by value, so that e.g. "1Gi" equals "1024Mi", 1 equals "1000m" and "90s" equals "1m30s".
+	// Values without a unit are only compared as quantities in resource quantity fields such as limits and
+	// requests, where values are never compared as durations.
+	SemanticComparison bool
+	// SemanticPaths enables the semantic comparison only for the values at or below the given dot-separated field
+	// paths, e.g. "spec.containers.resources". List indices are not part of the paths.
+	SemanticPaths []string
+
+	// path is the field path of the values being compared, e.g. ".spec.replicas".
+	path string
+}
+
+// semantic returns true if the values at the current path are compared semantically.
+func (o SubsetOptions) semantic() bool {
+	return o.SemanticComparison || semanticPathMatches(o.path, o.SemanticPaths)
+}
+
 // SubsetError is an error type used by IsSubset for tracking the path in the struct.
 type SubsetError struct {
 	path    []string
@@ -34,59 +76,250 @@ func (e *SubsetError) Error() string {
 	return fmt.Sprintf("%s: %s", path, e.message)
 }
 
+// SubsetErrors is returned by IsSubset if more than one value does not match, with one SubsetError per mismatch.
+type SubsetErrors []*SubsetError
+
+// Error implements the error interface, listing every mismatch on its own line.
+func (e SubsetErrors) Error() string {
+	mismatches := make([]string, 0, len(e))
+	for _, err := range e {
+		mismatches = append(mismatches, "  "+err.Error())
+	}
+	return fmt.Sprintf("%d mismatches:\n%s", len(e), strings.Join(mismatches, "\n"))
+}
+
+// Unwrap returns the individual mismatches.
+func (e SubsetErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
 // IsSubset checks to see if `expected` is a subset of `actual`. A "subset" is an object that is equivalent to
 // the other object, but where map keys found in actual that are not defined in expected are ignored.
+// Leaf values in expected may be value matchers such as "(regex ^v1\.)" or "(>= 2)", which are then
+// used instead of an equality comparison.
+// All mismatching values are reported, as SubsetErrors if there is more than one.
 func IsSubset(expected, actual interface{}) error {
+	return IsSubsetWithOptions(expected, actual, SubsetOptions{})
+}
+
+// IsSubsetWithOptions checks to see if `expected` is a subset of `actual` like IsSubset, with the comparison
+// configured by opts.
+func IsSubsetWithOptions(expected, actual interface{}, opts SubsetOptions) error {
+	switch errs := isSubset(expected, actual, opts); len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return SubsetErrors(errs)
+	}
+}
+
+// isSubset returns a SubsetError for every value of expected which does not match actual, in the order of their paths.
+func isSubset(expected, actual interface{}, opts SubsetOptions) []*SubsetError {
+	if matcher, isMatcher, err := parseMatcher(expected); isMatcher {
+		if err == nil {
+			err = matcher.match(actual)
+		}
+		if err != nil {
+			return []*SubsetError{{message: err.Error()}}
+		}
+		return nil
+	}
+	expected, _ = unescapeMatcher(expected)
+
+	if opts.semantic() {
+		if equal, comparable := semanticEqual(expected, actual, opts.path); comparable {
+			if !equal {
+				return []*SubsetError{{
+					message: fmt.Sprintf("value mismatch, expected: %v != actual: %v", expected, actual),
+				}}
+			}
+			return nil
+		}
+	}
+
 	if reflect.TypeOf(expected) != reflect.TypeOf(actual) {
-		return &SubsetError{
+		return []*SubsetError{{
 			message: fmt.Sprintf("type mismatch: %v != %v", reflect.TypeOf(expected), reflect.TypeOf(actual)),
-		}
+		}}
 	}
 
 	if reflect.DeepEqual(expected, actual) {
 		return nil
 	}
 
+	var errs []*SubsetError
+
 	switch reflect.TypeOf(expected).Kind() {
 	case reflect.Slice:
+		if opts.UnorderedLists {
+			if err := isUnorderedSubset(reflect.ValueOf(expected), reflect.ValueOf(actual), opts); err != nil {
+				return []*SubsetError{err}
+			}
+			return nil
+		}
+
 		if reflect.ValueOf(expected).Len() != reflect.ValueOf(actual).Len() {
-			return &SubsetError{
+			return []*SubsetError{{
 				message: fmt.Sprintf("slice length mismatch: %d != %d", reflect.ValueOf(expected).Len(), reflect.ValueOf(actual).Len()),
-			}
+			}}
 		}
 
 		for i := 0; i < reflect.ValueOf(expected).Len(); i++ {
-			if err := IsSubset(reflect.ValueOf(expected).Index(i).Interface(), reflect.ValueOf(actual).Index(i).Interface()); err != nil {
-				return err
-			}
+			errs = append(errs, isSubset(reflect.ValueOf(expected).Index(i).Interface(), reflect.ValueOf(actual).Index(i).Interface(), opts)...)
 		}
 	case reflect.Map:
-		iter := reflect.ValueOf(expected).MapRange()
+		keys := reflect.ValueOf(expected).MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
 
-		for iter.Next() {
-			actualValue := reflect.ValueOf(actual).MapIndex(iter.Key())
+		for _, key := range keys {
+			expectedValue := reflect.ValueOf(expected).MapIndex(key)
+			actualValue := reflect.ValueOf(actual).MapIndex(key)
 
 			if !actualValue.IsValid() {
-				return &SubsetError{
-					path:    []string{iter.Key().String()},
-					message: "key is missing from map",
+				if matcher, _, _ := parseMatcher(expectedValue.Interface()); matcher != nil && matcher.allowsMissing() {
+					continue
 				}
+				errs = append(errs, &SubsetError{
+					path:    []string{key.String()},
+					message: "key is missing from map",
+				})
+				continue
 			}
 
-			if err := IsSubset(iter.Value().Interface(), actualValue.Interface()); err != nil {
-				subsetErr, ok := err.(*SubsetError)
-				if ok {
-					subsetErr.AppendPath(iter.Key().String())
-					return subsetErr
-				}
-				return err
+			valueOpts := opts
+			valueOpts.path = opts.path + "." + key.String()
+			for _, err := range isSubset(expectedValue.Interface(), actualValue.Interface(), valueOpts) {
+				err.AppendPath(key.String())
+				errs = append(errs, err)
 			}
 		}
 	default:
-		return &SubsetError{
+		return []*SubsetError{{
 			message: fmt.Sprintf("value mismatch, expected: %v != actual: %v", expected, actual),
+		}}
+	}
+
+	return errs
+}
+
+// isUnorderedSubset checks that every element of expected is a subset of a distinct element of actual.
+// The assignment of expected to actual elements is found by searching for augmenting paths in the
+// bipartite graph of candidate matches, so that an expected element is never left unmatched only
+// because an earlier expected element claimed its sole candidate.
+func isUnorderedSubset(expected, actual reflect.Value, opts SubsetOptions) *SubsetError {
+	candidates := make([][]int, expected.Len())
+	for i := 0; i < expected.Len(); i++ {
+		for j := 0; j < actual.Len(); j++ {
+			if len(isSubset(expected.Index(i).Interface(), actual.Index(j).Interface(), opts)) == 0 {
+				candidates[i] = append(candidates[i], j)
+			}
+		}
+		if len(candidates[i]) == 0 {
+			return &SubsetError{
+				message: fmt.Sprintf("no element of the actual list (length %d) matches expected list element %d: %v",
+					actual.Len(), i, expected.Index(i).Interface()),
+			}
+		}
+	}
+
+	// matchedBy maps an actual element index to the expected element index it is assigned to.
+	matchedBy := map[int]int{}
+
+	var assign func(i int, visited map[int]bool) bool
+	assign = func(i int, visited map[int]bool) bool {
+		for _, j := range candidates[i] {
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			if owner, taken := matchedBy[j]; !taken || assign(owner, visited) {
+				matchedBy[j] = i
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range candidates {
+		if !assign(i, map[int]bool{}) {
+			return &SubsetError{
+				message: fmt.Sprintf("expected list element %d only matches actual elements %v, which are already matched by other expected elements: %v",
+					i, candidates[i], expected.Index(i).Interface()),
+			}
 		}
 	}
 
 	return nil
 }
+
+// SplitKuttlAnnotations returns a copy of the expected object without the annotations which configure kuttl,
+// together with those annotations. The annotations configure the comparison and must not be
+// compared with the actual object themselves.
+func SplitKuttlAnnotations(expected map[string]interface{}) (map[string]interface{}, map[string]string) {
+	kuttlAnnotations := map[string]string{}
+
+	metadata, ok := expected["metadata"].(map[string]interface{})
+	if !ok {
+		return expected, kuttlAnnotations
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return expected, kuttlAnnotations
+	}
+
+	for key, value := range annotations {
+		if kuttlAnnotationKeys[key] {
+			kuttlAnnotations[key] = fmt.Sprint(value)
+		}
+	}
+	if len(kuttlAnnotations) == 0 {
+		return expected, kuttlAnnotations
+	}
+
+	stripped := runtime.DeepCopyJSON(expected)
+	strippedMetadata := stripped["metadata"].(map[string]interface{})
+	strippedAnnotations := strippedMetadata["annotations"].(map[string]interface{})
+	for key := range kuttlAnnotations {
+		delete(strippedAnnotations, key)
+	}
+	if len(strippedAnnotations) == 0 {
+		delete(strippedMetadata, "annotations")
+	}
+
+	return stripped, kuttlAnnotations
+}
+
+// SubsetOptionsFromAnnotations builds the SubsetOptions configured by the kuttl.dev/ annotations of an expected object.
+func SubsetOptionsFromAnnotations(annotations map[string]string) (SubsetOptions, error) {
+	opts := SubsetOptions{}
+
+	switch listMatching := annotations[harness.ListMatchingAnnotation]; listMatching {
+	case "", harness.ListMatchingStrict:
+	case harness.ListMatchingUnordered:
+		opts.UnorderedLists = true
+	default:
+		return opts, fmt.Errorf("annotation %s has invalid value %q", harness.ListMatchingAnnotation, listMatching)
+	}
+
+	switch semantic := annotations[harness.SemanticComparisonAnnotation]; semantic {
+	case "", "false":
+	case "true":
+		opts.SemanticComparison = true
+	default:
+		for _, path := range strings.Split(semantic, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				return opts, fmt.Errorf("annotation %s has invalid value %q", harness.SemanticComparisonAnnotation, semantic)
+			}
+			opts.SemanticPaths = append(opts.SemanticPaths, path)
+		}
+	}
+
+	return opts, nil
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
 )
 
 func TestIsSubset(t *testing.T) {
@@ -124,3 +126,234 @@ func TestIsSubset(t *testing.T) {
 		},
 	}))
 }
+
+func TestIsSubsetReportsAllMismatches(t *testing.T) {
+	err := IsSubset(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"image": "nginx:1.25",
+			},
+			"paused": false,
+		},
+		"status": map[string]interface{}{
+			"readyReplicas": int64(3),
+		},
+	}, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{
+				"image": "nginx:1.24",
+			},
+			"paused": false,
+		},
+		"status": map[string]interface{}{},
+	})
+
+	var subsetErrors SubsetErrors
+	assert.ErrorAs(t, err, &subsetErrors)
+	assert.Len(t, subsetErrors, 3)
+	assert.EqualError(t, err, `3 mismatches:
+  .spec.replicas: value mismatch, expected: 3 != actual: 1
+  .spec.template.image: value mismatch, expected: nginx:1.25 != actual: nginx:1.24
+  .status.readyReplicas: key is missing from map`)
+
+	// a single mismatch is returned as it is
+	err = IsSubset(map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{"replicas": int64(1)})
+	assert.IsType(t, &SubsetError{}, err)
+	assert.EqualError(t, err, ".replicas: value mismatch, expected: 3 != actual: 1")
+}
+
+func TestIsSubsetUnorderedLists(t *testing.T) {
+	unordered := SubsetOptions{UnorderedLists: true}
+
+	conditions := map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available", "status": "True"},
+			map[string]interface{}{"type": "Progressing", "status": "True"},
+			map[string]interface{}{"type": "ReplicaFailure", "status": "False"},
+		},
+	}
+
+	expected := map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Progressing"},
+			map[string]interface{}{"type": "Available", "status": "True"},
+		},
+	}
+
+	assert.NotNil(t, IsSubset(expected, conditions))
+	assert.Nil(t, IsSubsetWithOptions(expected, conditions, unordered))
+
+	// every expected element must match a distinct actual element
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"status": "True"},
+			map[string]interface{}{"type": "Available"},
+		},
+	}, conditions, unordered))
+	assert.NotNil(t, IsSubsetWithOptions(map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available"},
+			map[string]interface{}{"type": "Available"},
+		},
+	}, conditions, unordered))
+
+	err := IsSubsetWithOptions(map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available"},
+			map[string]interface{}{"type": "Degraded"},
+		},
+	}, conditions, unordered)
+	assert.EqualError(t, err, ".conditions: no element of the actual list (length 3) matches expected list element 1: map[type:Degraded]")
+
+	// nested lists are matched unordered too
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"env": []interface{}{"b", "a"}},
+		},
+	}, map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "sidecar"},
+			map[string]interface{}{"env": []interface{}{"a", "b", "c"}},
+		},
+	}, unordered))
+}
+
+func TestIsSubsetSemanticComparison(t *testing.T) {
+	semantic := SubsetOptions{SemanticComparison: true}
+
+	resources := map[string]interface{}{
+		"limits": map[string]interface{}{
+			"cpu":    "1000m",
+			"memory": "1Gi",
+		},
+		"requests": map[string]interface{}{
+			"cpu": int64(1),
+		},
+	}
+
+	expected := map[string]interface{}{
+		"limits": map[string]interface{}{
+			"cpu":    int64(1),
+			"memory": "1024Mi",
+		},
+		"requests": map[string]interface{}{
+			"cpu": "1",
+		},
+	}
+
+	assert.NotNil(t, IsSubset(expected, resources))
+	assert.Nil(t, IsSubsetWithOptions(expected, resources, semantic))
+
+	assert.EqualError(t, IsSubsetWithOptions(map[string]interface{}{
+		"limits": map[string]interface{}{"memory": "1G"},
+	}, resources, semantic), ".limits.memory: value mismatch, expected: 1G != actual: 1Gi")
+
+	// timestamps are compared as points in time
+	startTime := map[string]interface{}{"startTime": "2024-01-02T03:04:05Z"}
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{"startTime": "2024-01-02T04:04:05+01:00"}, startTime, semantic))
+	assert.NotNil(t, IsSubsetWithOptions(map[string]interface{}{"startTime": "2024-01-02T04:04:05Z"}, startTime, semantic))
+
+	// other values are still compared as they are
+	assert.NotNil(t, IsSubsetWithOptions(map[string]interface{}{"phase": "Running"}, map[string]interface{}{"phase": "running"}, semantic))
+
+	// values with a unit are quantities anywhere
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{"size": "1024Mi"}, map[string]interface{}{"size": "1Gi"}, semantic))
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{"size": "1k"}, map[string]interface{}{"size": int64(1000)}, semantic))
+
+	// plain numbers are only quantities in resource quantity fields
+	for _, tt := range []struct {
+		expected, actual interface{}
+	}{
+		{"1", int64(1)},
+		{"1e3", "1000"},
+		{"3.10", "3.1"},
+		{"1.0", "1"},
+		{"2", "2.0"},
+	} {
+		assert.NotNil(t, IsSubsetWithOptions(map[string]interface{}{"version": tt.expected}, map[string]interface{}{"version": tt.actual}, semantic),
+			"%v should not match %v", tt.expected, tt.actual)
+	}
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{"limits": map[string]interface{}{"cpu": "1e3"}},
+		map[string]interface{}{"limits": map[string]interface{}{"cpu": "1000"}}, semantic))
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{"emptyDir": map[string]interface{}{"sizeLimit": "1"}},
+		map[string]interface{}{"emptyDir": map[string]interface{}{"sizeLimit": int64(1)}}, semantic))
+
+	// semantic paths only apply at or below the given paths
+	byPath := SubsetOptions{SemanticPaths: []string{"limits"}}
+	assert.Nil(t, IsSubsetWithOptions(map[string]interface{}{
+		"limits": map[string]interface{}{"memory": "1024Mi"},
+	}, resources, byPath))
+	assert.NotNil(t, IsSubsetWithOptions(map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "1000m"},
+	}, resources, byPath))
+}
+
+func TestSubsetOptionsFromAnnotations(t *testing.T) {
+	opts, err := SubsetOptionsFromAnnotations(map[string]string{
+		harness.ListMatchingAnnotation:       harness.ListMatchingUnordered,
+		harness.SemanticComparisonAnnotation: "true",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, SubsetOptions{UnorderedLists: true, SemanticComparison: true}, opts)
+
+	opts, err = SubsetOptionsFromAnnotations(map[string]string{
+		harness.SemanticComparisonAnnotation: "spec.containers.resources, status.startTime",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, SubsetOptions{SemanticPaths: []string{"spec.containers.resources", "status.startTime"}}, opts)
+
+	_, err = SubsetOptionsFromAnnotations(map[string]string{harness.SemanticComparisonAnnotation: "spec,,status"})
+	assert.EqualError(t, err, `annotation kuttl.dev/semantic-comparison has invalid value "spec,,status"`)
+
+	_, err = SubsetOptionsFromAnnotations(map[string]string{harness.ListMatchingAnnotation: "Sorted"})
+	assert.EqualError(t, err, `annotation kuttl.dev/list-matching has invalid value "Sorted"`)
+}
+
+func TestSplitKuttlAnnotations(t *testing.T) {
+	expected := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "hello",
+			"annotations": map[string]interface{}{
+				"kuttl.dev/list-matching": "Unordered",
+			},
+		},
+	}
+
+	stripped, annotations := SplitKuttlAnnotations(expected)
+	assert.Equal(t, map[string]interface{}{"metadata": map[string]interface{}{"name": "hello"}}, stripped)
+	assert.Equal(t, map[string]string{"kuttl.dev/list-matching": "Unordered"}, annotations)
+	// the original object is left untouched
+	assert.Contains(t, expected["metadata"], "annotations")
+
+	opts, err := SubsetOptionsFromAnnotations(annotations)
+	assert.NoError(t, err)
+	assert.True(t, opts.UnorderedLists)
+
+	_, err = SubsetOptionsFromAnnotations(map[string]string{"kuttl.dev/list-matching": "sorted"})
+	assert.EqualError(t, err, `annotation kuttl.dev/list-matching has invalid value "sorted"`)
+}
+
+func TestSplitKuttlAnnotationsKeepsOtherAnnotations(t *testing.T) {
+	expected := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "hello",
+			"annotations": map[string]interface{}{
+				"kuttl.dev/count":        "2",
+				"kuttl.dev/owner":        "zookeeper",
+				"example.com/list-match": "Unordered",
+			},
+		},
+	}
+
+	stripped, annotations := SplitKuttlAnnotations(expected)
+	assert.Equal(t, map[string]interface{}{"metadata": map[string]interface{}{
+		"name": "hello",
+		"annotations": map[string]interface{}{
+			"kuttl.dev/owner":        "zookeeper",
+			"example.com/list-match": "Unordered",
+		},
+	}}, stripped)
+	assert.Equal(t, map[string]string{"kuttl.dev/count": "2"}, annotations)
+}
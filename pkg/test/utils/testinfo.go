@@ -0,0 +1,71 @@
+package utils
+
+// Contains the metadata of the test running a command, which is exported to the command.
+
+import (
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/stackabletech/kuttl/pkg/version"
+)
+
+// TestInfo describes the test which runs a command. RunCommand exports it to the command as environment variables
+// with the prefix KUTTL_, see Env.
+type TestInfo struct {
+	// Suite is the name of the test suite, i.e. the name in the metadata of the TestSuite.
+	Suite string
+	// SuiteDir is the absolute test directory of the test suite the test case was loaded from.
+	SuiteDir string
+	// Test is the name of the test case.
+	Test string
+	// TestDir is the absolute directory of the test case.
+	TestDir string
+	// ArtifactsDir is the absolute artifacts directory of the test suite.
+	ArtifactsDir string
+	// RunLabels are the labels the test suite is run with.
+	RunLabels labels.Set
+	// Step is the test step which runs the command, if any.
+	Step *StepInfo
+}
+
+// StepInfo describes the test step which runs a command.
+type StepInfo struct {
+	Index int
+	Name  string
+}
+
+// WithStep returns a copy of i for the test step with the given index and name.
+func (i *TestInfo) WithStep(index int, name string) *TestInfo {
+	info := &TestInfo{}
+	if i != nil {
+		*info = *i
+	}
+	info.Step = &StepInfo{Index: index, Name: name}
+	return info
+}
+
+// Env returns the environment variables of the test. All variables are set, those which do not apply, e.g. the
+// step of a command of the test suite, to the empty string. The version of kuttl is also set if i is nil.
+func (i *TestInfo) Env() map[string]string {
+	env := map[string]string{
+		"KUTTL_VERSION": version.Get().GitVersion,
+	}
+	if i == nil {
+		return env
+	}
+
+	env["KUTTL_SUITE"] = i.Suite
+	env["KUTTL_SUITE_DIR"] = i.SuiteDir
+	env["KUTTL_TEST"] = i.Test
+	env["KUTTL_TEST_DIR"] = i.TestDir
+	env["KUTTL_ARTIFACTS_DIR"] = i.ArtifactsDir
+	env["KUTTL_RUN_LABELS"] = i.RunLabels.String()
+	env["KUTTL_STEP_INDEX"] = ""
+	env["KUTTL_STEP_NAME"] = ""
+	if i.Step != nil {
+		env["KUTTL_STEP_INDEX"] = strconv.Itoa(i.Step.Index)
+		env["KUTTL_STEP_NAME"] = i.Step.Name
+	}
+	return env
+}
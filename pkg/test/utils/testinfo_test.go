@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+
+	harness "github.com/stackabletech/kuttl/pkg/apis/testharness/v1beta1"
+	"github.com/stackabletech/kuttl/pkg/version"
+)
+
+func TestTestInfoEnv(t *testing.T) {
+	kuttlVersion := version.Get().GitVersion
+
+	var info *TestInfo
+	assert.Equal(t, map[string]string{"KUTTL_VERSION": kuttlVersion}, info.Env())
+
+	info = &TestInfo{
+		Suite:        "e2e",
+		SuiteDir:     "tests/e2e",
+		Test:         "smoke",
+		TestDir:      "/kuttl/tests/e2e/smoke",
+		ArtifactsDir: "/kuttl/artifacts",
+		RunLabels:    labels.Set{"flavor": "a", "arch": "arm64"},
+	}
+	assert.Equal(t, map[string]string{
+		"KUTTL_VERSION":       kuttlVersion,
+		"KUTTL_SUITE":         "e2e",
+		"KUTTL_SUITE_DIR":     "tests/e2e",
+		"KUTTL_TEST":          "smoke",
+		"KUTTL_TEST_DIR":      "/kuttl/tests/e2e/smoke",
+		"KUTTL_ARTIFACTS_DIR": "/kuttl/artifacts",
+		"KUTTL_RUN_LABELS":    "arch=arm64,flavor=a",
+		"KUTTL_STEP_INDEX":    "",
+		"KUTTL_STEP_NAME":     "",
+	}, info.Env())
+
+	env := info.WithStep(0, "install").Env()
+	assert.Equal(t, "0", env["KUTTL_STEP_INDEX"])
+	assert.Equal(t, "install", env["KUTTL_STEP_NAME"])
+	assert.Equal(t, "smoke", env["KUTTL_TEST"])
+
+	// the test info is not changed
+	assert.Nil(t, info.Step)
+}
+
+func TestRunCommandTestInfo(t *testing.T) {
+	info := (&TestInfo{Test: "smoke"}).WithStep(2, "check")
+	logger := NewTestLogger(t, "")
+
+	hcmd := harness.Command{Script: `test "$KUTTL_TEST" = smoke && test "$KUTTL_STEP_INDEX" = 2`}
+
+	_, err := RunCommandWithOptions(context.TODO(), "world", hcmd, "", logger, logger, logger, 0, "", CommandOptions{TestInfo: info})
+	assert.NoError(t, err)
+
+	// background commands get the same variables
+	hcmd.Background = true
+	cmd, err := RunCommandWithOptions(context.TODO(), "world", hcmd, "", logger, logger, logger, 0, "", CommandOptions{TestInfo: info})
+	require.NoError(t, err)
+	assert.NoError(t, cmd.Wait())
+
+	// the variables are also expanded in commands
+	_, err = RunCommandWithOptions(context.TODO(), "world", harness.Command{Command: "test $KUTTL_STEP_NAME = check"}, "", logger, logger, logger, 0, "", CommandOptions{TestInfo: info})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,350 @@
+package utils
+
+// Contains a watch-based cache that lets test steps re-evaluate their assertions as soon as the objects they
+// reference change, while serving their reads without hitting the API server.
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// WatchTarget identifies objects a test step is interested in: all objects of a kind in a namespace.
+// Events for cluster-scoped objects of the kind are always delivered.
+type WatchTarget struct {
+	GVK       schema.GroupVersionKind
+	Namespace string
+}
+
+// WatchCache maintains informers for the watched kinds, shared by the test steps subscribed to them. Namespaced
+// kinds are watched in the namespaces of the subscriptions only, cluster-scoped kinds cluster-wide.
+// Informers are started lazily, the first time a kind is subscribed to in a namespace, and stopped when the last
+// subscription to them is closed. If an informer can not list or watch its kind, e.g. because of missing
+// permissions, it is stopped and reads fall back to the API server.
+type WatchCache struct {
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+
+	lock      sync.Mutex
+	informers map[informerKey]*kindInformer
+	stopped   bool
+}
+
+// informerKey identifies the informer of a kind in a namespace, which is empty for cluster-wide informers.
+type informerKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+}
+
+type kindInformer struct {
+	informer informers.GenericInformer
+	stopCh   chan struct{}
+	// subscriptions is the number of open subscriptions using the informer, guarded by the lock of the cache.
+	subscriptions int
+
+	lock   sync.Mutex
+	failed bool
+}
+
+// NewWatchCache creates a WatchCache for the cluster described by cfg.
+func NewWatchCache(cfg *rest.Config) (*WatchCache, error) {
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWatchCache(dynamicClient, mapper), nil
+}
+
+func newWatchCache(dynamicClient dynamic.Interface, mapper meta.RESTMapper) *WatchCache {
+	return &WatchCache{
+		dynamic:   dynamicClient,
+		mapper:    mapper,
+		informers: map[informerKey]*kindInformer{},
+	}
+}
+
+// subscribe returns the key and the informer for gvk in namespace, or cluster-wide if gvk is cluster-scoped or
+// namespace is empty, starting it if necessary, and counts the subscription to it.
+func (w *WatchCache) subscribe(gvk schema.GroupVersionKind, namespace string) (informerKey, *kindInformer, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.stopped {
+		return informerKey{}, nil, nil
+	}
+
+	mapping, err := w.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return informerKey{}, nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		namespace = metav1.NamespaceAll
+	}
+
+	key := informerKey{gvk: gvk, namespace: namespace}
+	if inf, ok := w.informers[key]; ok {
+		inf.subscriptions++
+		return key, inf, nil
+	}
+
+	inf := &kindInformer{
+		informer: dynamicinformer.NewFilteredDynamicInformer(w.dynamic, mapping.Resource, namespace, 0,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil),
+		stopCh:        make(chan struct{}),
+		subscriptions: 1,
+	}
+	if err := inf.informer.Informer().SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		if k8serrors.IsForbidden(err) || k8serrors.IsNotFound(err) || k8serrors.IsMethodNotSupported(err) {
+			inf.stop()
+			return
+		}
+		cache.DefaultWatchErrorHandler(r, err)
+	}); err != nil {
+		return informerKey{}, nil, err
+	}
+	w.informers[key] = inf
+
+	go inf.informer.Informer().Run(inf.stopCh)
+
+	return key, inf, nil
+}
+
+// unsubscribe stops the informer of key if inf was its last subscription.
+func (w *WatchCache) unsubscribe(key informerKey, inf *kindInformer) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	inf.subscriptions--
+	if inf.subscriptions == 0 && w.informers[key] == inf {
+		inf.stop()
+		delete(w.informers, key)
+	}
+}
+
+func (i *kindInformer) stop() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if !i.failed {
+		i.failed = true
+		close(i.stopCh)
+	}
+}
+
+// synced returns true if reads can be served from the informer.
+func (i *kindInformer) synced() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return !i.failed && i.informer.Informer().HasSynced()
+}
+
+// Subscription delivers a notification on C whenever an object matching one of its targets changes.
+// Notifications are coalesced: C has a buffer of one and is never closed.
+type Subscription struct {
+	C <-chan struct{}
+
+	cache         *WatchCache
+	registrations map[informerKey]subscribedInformer
+}
+
+type subscribedInformer struct {
+	informer     *kindInformer
+	registration cache.ResourceEventHandlerRegistration
+}
+
+// Subscribe starts watching the kinds of the targets and returns a subscription for changes to them.
+// Kinds that can not be watched are skipped, so callers should keep polling as a fallback.
+func (w *WatchCache) Subscribe(targets ...WatchTarget) *Subscription {
+	c := make(chan struct{}, 1)
+	sub := &Subscription{C: c, cache: w, registrations: map[informerKey]subscribedInformer{}}
+
+	notify := func(obj interface{}) {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+
+	for _, target := range targets {
+		key, inf, err := w.subscribe(target.GVK, target.Namespace)
+		if err != nil || inf == nil {
+			continue
+		}
+		if _, ok := sub.registrations[key]; ok {
+			w.unsubscribe(key, inf)
+			continue
+		}
+
+		registration, err := inf.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    notify,
+			UpdateFunc: func(_, newObj interface{}) { notify(newObj) },
+			DeleteFunc: notify,
+		})
+		if err != nil {
+			w.unsubscribe(key, inf)
+			continue
+		}
+		sub.registrations[key] = subscribedInformer{informer: inf, registration: registration}
+	}
+
+	return sub
+}
+
+// Close stops the delivery of notifications and the informers no other subscription uses.
+func (s *Subscription) Close() {
+	for key, subscribed := range s.registrations {
+		_ = subscribed.informer.informer.Informer().RemoveEventHandler(subscribed.registration)
+		s.cache.unsubscribe(key, subscribed.informer)
+	}
+	s.registrations = nil
+}
+
+// Stop stops all informers of the cache.
+func (w *WatchCache) Stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, inf := range w.informers {
+		inf.stop()
+	}
+	w.stopped = true
+}
+
+// Client wraps cl so that Get and List calls for unstructured objects are served from the cache, if the
+// informer of their kind has synced. All other calls are passed through to cl.
+func (w *WatchCache) Client(cl client.Client) client.Client {
+	return &cachedClient{Client: cl, cache: w}
+}
+
+type cachedClient struct {
+	client.Client
+	cache *WatchCache
+}
+
+// syncedInformer returns an informer for the objects of gvk in namespace, or of all namespaces if it is empty,
+// if one has already been started and has synced.
+func (c *cachedClient) syncedInformer(gvk schema.GroupVersionKind, namespace string) *kindInformer {
+	c.cache.lock.Lock()
+	inf := c.cache.informers[informerKey{gvk: gvk, namespace: namespace}]
+	if inf == nil {
+		inf = c.cache.informers[informerKey{gvk: gvk, namespace: metav1.NamespaceAll}]
+	}
+	c.cache.lock.Unlock()
+
+	if inf == nil || !inf.synced() {
+		return nil
+	}
+	return inf
+}
+
+// Get retrieves an obj from the cache, or from the API server if its kind is not cached.
+func (c *cachedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || len(opts) > 0 {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	inf := c.syncedInformer(u.GroupVersionKind(), key.Namespace)
+	if inf == nil {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+
+	lister := inf.informer.Lister()
+	var (
+		cached runtime.Object
+		err    error
+	)
+	if key.Namespace == "" {
+		cached, err = lister.Get(key.Name)
+	} else {
+		cached, err = lister.ByNamespace(key.Namespace).Get(key.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	cachedObj, ok := cached.(*unstructured.Unstructured)
+	if !ok {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	gvk := u.GroupVersionKind()
+	cachedObj.DeepCopyInto(u)
+	u.SetGroupVersionKind(gvk)
+	return nil
+}
+
+// List retrieves a list of objects from the cache, or from the API server if their kind is not cached or the
+// list options can not be evaluated against the cache.
+func (c *cachedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	u, ok := list.(*unstructured.UnstructuredList)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector != nil || listOpts.Limit != 0 || listOpts.Continue != "" || listOpts.Raw != nil {
+		return c.Client.List(ctx, list, opts...)
+	}
+
+	gvk := u.GroupVersionKind()
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	inf := c.syncedInformer(gvk, listOpts.Namespace)
+	if inf == nil {
+		return c.Client.List(ctx, list, opts...)
+	}
+
+	selector := listOpts.LabelSelector
+	if selector == nil {
+		selector = labels.Everything()
+	}
+
+	lister := inf.informer.Lister()
+	var (
+		cached []runtime.Object
+		err    error
+	)
+	if listOpts.Namespace == "" {
+		cached, err = lister.List(selector)
+	} else {
+		cached, err = lister.ByNamespace(listOpts.Namespace).List(selector)
+	}
+	if err != nil {
+		return err
+	}
+
+	u.Items = make([]unstructured.Unstructured, 0, len(cached))
+	for _, obj := range cached {
+		cachedObj, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return c.Client.List(ctx, list, opts...)
+		}
+		u.Items = append(u.Items, *cachedObj.DeepCopy())
+	}
+	return nil
+}
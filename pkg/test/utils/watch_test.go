@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var (
+	podGVK = schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	podGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+)
+
+func newTestWatchCache(t *testing.T, objs ...runtime.Object) (*WatchCache, *dynamicfake.FakeDynamicClient) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(podGVK, meta.RESTScopeNamespace)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{podGVR: "PodList"}, objs...)
+	w := newWatchCache(dynamicClient, mapper)
+	t.Cleanup(w.Stop)
+	return w, dynamicClient
+}
+
+func waitForSync(t *testing.T, w *WatchCache, gvk schema.GroupVersionKind, namespace string) {
+	require.Eventually(t, func() bool {
+		w.lock.Lock()
+		inf := w.informers[informerKey{gvk: gvk, namespace: namespace}]
+		w.lock.Unlock()
+		return inf != nil && inf.synced()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchCacheSubscribe(t *testing.T) {
+	w, dynamicClient := newTestWatchCache(t)
+
+	sub := w.Subscribe(WatchTarget{GVK: podGVK, Namespace: "test"})
+	defer sub.Close()
+	waitForSync(t, w, podGVK, "test")
+
+	pods := dynamicClient.Resource(podGVR)
+
+	// the watch is established asynchronously after the initial list, so keep creating pods until one is seen
+	created := 0
+	require.Eventually(t, func() bool {
+		created++
+		_, err := pods.Namespace("test").Create(context.TODO(), NewPod(fmt.Sprintf("hello-%d", created), "test"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		select {
+		case <-sub.C:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err := pods.Namespace("other").Create(context.TODO(), NewPod("ignored", "other"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	select {
+	case <-sub.C:
+		t.Fatal("received a notification for another namespace")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatchCacheClient(t *testing.T) {
+	labeled := NewPod("labeled", "test")
+	labeled.SetLabels(map[string]string{"app": "zk"})
+
+	w, _ := newTestWatchCache(t, NewPod("hello", "test"), labeled, NewPod("other", "other"))
+	sub := w.Subscribe(WatchTarget{GVK: podGVK, Namespace: "test"})
+	defer sub.Close()
+	waitForSync(t, w, podGVK, "test")
+
+	// the fallback client is empty, so all objects must come from the cache
+	cl := w.Client(fake.NewClientBuilder().Build())
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(podGVK)
+	require.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "hello"}, actual))
+	assert.Equal(t, "hello", actual.GetName())
+	assert.Equal(t, podGVK, actual.GroupVersionKind())
+
+	err := cl.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "missing"}, actual)
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(podGVK)
+	require.NoError(t, cl.List(context.TODO(), list, client.InNamespace("test")))
+	assert.Len(t, list.Items, 2)
+
+	require.NoError(t, cl.List(context.TODO(), list, client.InNamespace("test"), client.MatchingLabels{"app": "zk"}))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "labeled", list.Items[0].GetName())
+
+	// only the namespace of the subscription is cached, so the other namespaces are read from the empty fallback client
+	err = cl.Get(context.TODO(), client.ObjectKey{Namespace: "other", Name: "other"}, actual)
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	require.NoError(t, cl.List(context.TODO(), list))
+	assert.Empty(t, list.Items)
+}
+
+func TestWatchCacheSubscribeAllNamespaces(t *testing.T) {
+	w, _ := newTestWatchCache(t, NewPod("hello", "test"), NewPod("other", "other"))
+	sub := w.Subscribe(WatchTarget{GVK: podGVK})
+	defer sub.Close()
+	waitForSync(t, w, podGVK, metav1.NamespaceAll)
+
+	cl := w.Client(fake.NewClientBuilder().Build())
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(podGVK)
+	require.NoError(t, cl.List(context.TODO(), list))
+	assert.Len(t, list.Items, 2)
+
+	require.NoError(t, cl.List(context.TODO(), list, client.InNamespace("other")))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "other", list.Items[0].GetName())
+}
+
+func TestWatchCacheSubscriptionClose(t *testing.T) {
+	w, _ := newTestWatchCache(t)
+
+	first := w.Subscribe(WatchTarget{GVK: podGVK, Namespace: "test"})
+	second := w.Subscribe(WatchTarget{GVK: podGVK, Namespace: "test"}, WatchTarget{GVK: podGVK, Namespace: "test"})
+	waitForSync(t, w, podGVK, "test")
+	assert.Len(t, w.informers, 1)
+
+	first.Close()
+	assert.Len(t, w.informers, 1, "the informer is still used by the second subscription")
+
+	second.Close()
+	assert.Empty(t, w.informers)
+}
+
+func TestWatchCacheClientFallback(t *testing.T) {
+	w, _ := newTestWatchCache(t)
+
+	// no informer has been started for pods, so the fallback client is used
+	cl := w.Client(fake.NewClientBuilder().WithRuntimeObjects(NewPod("hello", "test")).Build())
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(podGVK)
+	require.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "hello"}, actual))
+	assert.Equal(t, "hello", actual.GetName())
+}